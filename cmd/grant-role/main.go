@@ -0,0 +1,54 @@
+// Command grant-role assigns a role to a user by email. It is how the first
+// admin is created, since only admins can assign roles through the API.
+//
+//	go run cmd/grant-role/main.go -email someone@example.com -role admin
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/joho/godotenv"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+)
+
+func main() {
+	email := flag.String("email", "", "email of the user")
+	role := flag.String("role", string(models.RoleAdmin), "role to assign")
+	flag.Parse()
+
+	if *email == "" {
+		log.Fatal("❌ -email is required")
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️ No .env file found, using system env")
+	}
+
+	services.InitEncryption()
+	config.ConnectDatabase()
+	services.SeedRoles()
+
+	if !services.RoleExists(models.RoleName(*role)) {
+		log.Fatalf("❌ Unknown role %q", *role)
+	}
+
+	var user models.User
+	if err := config.DB.Where("email = ?", *email).First(&user).Error; err != nil {
+		log.Fatalf("❌ No user with email %s", *email)
+	}
+
+	if err := config.DB.Model(&user).Update("role", *role).Error; err != nil {
+		log.Fatal("❌ Failed to assign role:", err)
+	}
+
+	services.WriteAuditEvent(&models.AuditEvent{
+		UserID:   &user.ID,
+		Action:   models.AuditRoleAssigned,
+		Metadata: map[string]any{"to": *role, "via": "cli"},
+	})
+	log.Printf("✅ %s is now %s", *email, *role)
+}
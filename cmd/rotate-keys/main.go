@@ -0,0 +1,97 @@
+// Command rotate-keys re-encrypts stored OAuth tokens under the active
+// encryption key. Run it after adding a new key and making it active; once it
+// reports zero remaining rows the retired key can be removed from
+// TOKEN_ENCRYPTION_KEYS.
+//
+// It also migrates rows the server no longer reads: plaintext written before
+// encryption was enabled and values from before tokens were bound to their
+// row. Run it once, before starting a release that refuses them.
+package main
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/services"
+)
+
+const (
+	batchSize = 200
+	table     = "oauth_accounts"
+)
+
+// storedTokens reads the columns as stored, bypassing the encrypted serializer
+type storedTokens struct {
+	ID           uuid.UUID
+	AccessToken  string
+	RefreshToken string
+}
+
+func main() {
+	if err := godotenv.Load(); err != nil {
+		log.Println("⚠️ No .env file found, using system env")
+	}
+
+	services.InitEncryption()
+	activeID := services.ActiveEncryptionKeyID()
+	if activeID == "" {
+		log.Fatal("❌ TOKEN_ENCRYPTION_KEYS must be set to rotate keys")
+	}
+
+	config.ConnectDatabase()
+
+	// Anything not already sealed under the active key in the current format,
+	// soft-deleted accounts included
+	prefix := services.EncryptedWithPrefix(activeID) + "%"
+	stale := func() *gorm.DB {
+		return config.DB.Table(table).
+			Where("(access_token <> '' AND access_token NOT LIKE ?) OR (refresh_token <> '' AND refresh_token NOT LIKE ?)", prefix, prefix)
+	}
+
+	var rows []storedTokens
+	rotated, changed := 0, 0
+	result := stale().Select("id", "access_token", "refresh_token").
+		FindInBatches(&rows, batchSize, func(tx *gorm.DB, batch int) error {
+			for _, row := range rows {
+				accessToken, err := services.ReencryptString(row.AccessToken, services.EncryptionContext(table, "access_token", row.ID))
+				if err != nil {
+					return err
+				}
+				refreshToken, err := services.ReencryptString(row.RefreshToken, services.EncryptionContext(table, "refresh_token", row.ID))
+				if err != nil {
+					return err
+				}
+
+				// Only if the server has not refreshed the tokens since they were
+				// read; its write is already sealed under the active key
+				update := config.DB.Table(table).
+					Where("id = ? AND access_token = ? AND refresh_token = ?", row.ID, row.AccessToken, row.RefreshToken).
+					Updates(map[string]any{
+						"access_token":  accessToken,
+						"refresh_token": refreshToken,
+					})
+				if update.Error != nil {
+					return update.Error
+				}
+				if update.RowsAffected == 0 {
+					changed++
+					continue
+				}
+				rotated++
+			}
+			log.Printf("🔑 Re-encrypted batch %d (%d accounts so far)", batch, rotated)
+			return nil
+		})
+	if result.Error != nil {
+		log.Fatal("❌ Key rotation failed:", result.Error)
+	}
+
+	var remaining int64
+	stale().Count(&remaining)
+
+	log.Printf("✅ Re-encrypted %d OAuth accounts under key %q, skipped %d updated meanwhile, %d remaining", rotated, activeID, changed, remaining)
+}
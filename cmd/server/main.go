@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/joho/godotenv"
 
 	"build-in-public/internal/config"
+	middleware "build-in-public/internal/middlewares"
 	"build-in-public/internal/routes"
 	"build-in-public/internal/services"
 )
@@ -19,20 +21,39 @@ func main() {
 		log.Println("⚠️ No .env file found, using system env")
 	}
 
+	services.InitEncryption()
 	config.ConnectDatabase()
+	services.SeedRoles()
 	services.InitOAuth()
+	services.InitOIDC()
+	services.InitSessionPolicy()
+	services.InitPasswordHashing()
+	services.InitPasswordPolicy()
+	services.InitTokenSigning()
+	services.InitRefreshTokens()
+	services.InitMailer()
+	services.InitSMS()
+	services.InitWebAuthn()
+	services.InitRateLimiting()
+	services.InitLockoutPolicy()
+	services.InitAccountDeletion()
+	services.InitImpersonation()
+	services.StartOAuthTokenRefresher(context.Background())
+	services.StartAccountPurger(context.Background())
 
 	r := gin.Default()
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // SvelteKit dev
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Content-Type", "Cookie"},
+		AllowHeaders:     []string{"Content-Type", "Cookie", "Authorization", services.CSRFHeaderName},
 		AllowCredentials: true, // 🔥 REQUIRED FOR COOKIES
-		ExposeHeaders:    []string{"Set-Cookie"},
+		ExposeHeaders:    []string{"Set-Cookie", services.ImpersonationHeader},
 		MaxAge:           12 * time.Hour,
 	}))
+	r.Use(middleware.CSRF())
 
 	routes.RegisterAuthRoutes(r)
 	routes.RegisterUserRoutes(r)
+	routes.RegisterAdminRoutes(r)
 	r.Run(":" + os.Getenv("APP_PORT"))
 }
@@ -1,9 +1,14 @@
 import { OpenAPI } from "./api/core/OpenAPI";
+import { getCSRFToken } from "./api";
 
 // Configure the OpenAPI client
 OpenAPI.BASE = import.meta.env.VITE_API_URL || "";
 OpenAPI.WITH_CREDENTIALS = true;
 OpenAPI.CREDENTIALS = "include";
+OpenAPI.HEADERS = async (options) =>
+  ["GET", "HEAD", "OPTIONS"].includes(options.method)
+    ? {}
+    : { "X-CSRF-Token": await getCSRFToken() };
 
 // Re-export the AuthService and ApiError for easy access
 export { AuthService } from "./api/services/AuthService";
@@ -3,11 +3,34 @@ export type { dto_SuccessResponse } from "./api/models/dto_SuccessResponse";
 
 const API_URL = import.meta.env.VITE_API_URL;
 
+const SAFE_METHODS = ["GET", "HEAD", "OPTIONS"];
+
+let csrfToken: Promise<string> | null = null;
+
+// The API's csrf_token cookie is on another origin, so the token is fetched
+// once and echoed in the X-CSRF-Token header on every mutating request.
+export function getCSRFToken(): Promise<string> {
+  csrfToken ??= fetch(`${API_URL}/auth/csrf`, { credentials: "include" })
+    .then((response) => response.json())
+    .then((body: { csrf_token: string }) => body.csrf_token)
+    .catch((error) => {
+      csrfToken = null;
+      throw error;
+    });
+  return csrfToken;
+}
+
 export async function apiFetch(path: string, options: RequestInit = {}) {
+  const method = (options.method || "GET").toUpperCase();
+  const csrfHeader: Record<string, string> = SAFE_METHODS.includes(method)
+    ? {}
+    : { "X-CSRF-Token": await getCSRFToken() };
+
   return fetch(`${API_URL}${path}`, {
     ...options,
     headers: {
       "Content-Type": "application/json",
+      ...csrfHeader,
       ...(options.headers || {}),
     },
     credentials: "include",
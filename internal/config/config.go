@@ -1 +1,10 @@
 package config
+
+import "os"
+
+// IsDevelopment reports whether APP_ENV is "development". Anything else,
+// including unset, is treated as production so that development shortcuts
+// never switch on by accident.
+func IsDevelopment() bool {
+	return os.Getenv("APP_ENV") == "development"
+}
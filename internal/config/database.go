@@ -31,14 +31,73 @@ func ConnectDatabase() {
 		log.Fatal("❌ Database connection failed:", err)
 	}
 
-	err = DB.AutoMigrate(
+	if err := Migrate(DB); err != nil {
+		log.Fatal("❌ Migration failed:", err)
+	}
+	log.Println("✅ Database connected & migrated")
+}
+
+// Migrate brings the schema up to date and installs the audit log trigger
+func Migrate(db *gorm.DB) error {
+	err := db.AutoMigrate(
 		&models.User{},
 		&models.Session{},
 		&models.SocialAccount{},
 		&models.OAuthAccount{},
+		&models.UserToken{},
+		&models.TOTPCredential{},
+		&models.RecoveryCode{},
+		&models.MFAChallenge{},
+		&models.WebAuthnCredential{},
+		&models.WebAuthnCeremony{},
+		&models.OAuthState{},
+		&models.RateLimitBucket{},
+		&models.PersonalAccessToken{},
+		&models.Permission{},
+		&models.Role{},
+		&models.AuditEvent{},
+		&models.PhoneVerification{},
+		&models.RefreshToken{},
 	)
 	if err != nil {
-		log.Fatal("❌ AutoMigrate failed:", err)
+		return fmt.Errorf("auto migrate: %w", err)
 	}
-	log.Println("✅ Database connected & migrated")
+
+	if err := db.Exec(auditAppendOnlySQL).Error; err != nil {
+		return fmt.Errorf("audit log trigger: %w", err)
+	}
+	return nil
 }
+
+// auditAppendOnlySQL makes the audit log append-only: application code cannot
+// change or remove an event once written. The one exception is purging an
+// account, which sets app.audit_purge in its transaction to blank the IP
+// address, user agent and metadata of the account's events; the events
+// themselves, and who did what when, stay. This guards against bugs and misuse
+// of the app, not against someone with direct database access, who can drop
+// the trigger; events are not chained, so such edits would go unnoticed.
+const auditAppendOnlySQL = `
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+	IF TG_OP = 'UPDATE'
+		AND current_setting('app.audit_purge', true) = 'on'
+		AND NEW.id = OLD.id
+		AND NEW.user_id IS NOT DISTINCT FROM OLD.user_id
+		AND NEW.actor_id IS NOT DISTINCT FROM OLD.actor_id
+		AND NEW.action = OLD.action
+		AND NEW.created_at = OLD.created_at
+		AND COALESCE(NEW.ip_address, '') IN ('', OLD.ip_address)
+		AND COALESCE(NEW.user_agent, '') IN ('', OLD.user_agent)
+		AND (NEW.metadata IS NULL OR NEW.metadata = OLD.metadata)
+	THEN
+		RETURN NEW;
+	END IF;
+	RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS audit_events_append_only ON audit_events;
+CREATE TRIGGER audit_events_append_only
+	BEFORE UPDATE OR DELETE ON audit_events
+	FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+`
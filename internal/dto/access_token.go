@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"build-in-public/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AccessTokenResponse struct {
+	ID         uuid.UUID      `json:"id"`
+	Name       string         `json:"name"`
+	Hint       string         `json:"hint"`
+	Scopes     []models.Scope `json:"scopes"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+	LastUsedIP string         `json:"last_used_ip,omitempty"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	Expired    bool           `json:"expired"`
+	CreatedAt  time.Time      `json:"created_at"`
+}
+
+// CreatedAccessTokenResponse is returned once, when the token is created;
+// the raw token cannot be retrieved again
+type CreatedAccessTokenResponse struct {
+	AccessTokenResponse
+	Token string `json:"token"`
+}
+
+func ToAccessTokenResponse(token models.PersonalAccessToken) AccessTokenResponse {
+	return AccessTokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Hint:       token.Hint,
+		Scopes:     token.Scopes,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+		ExpiresAt:  token.ExpiresAt,
+		Expired:    token.Expired(time.Now()),
+		CreatedAt:  token.CreatedAt,
+	}
+}
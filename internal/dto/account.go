@@ -0,0 +1,49 @@
+package dto
+
+import (
+	"time"
+
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type AccountDeletionResponse struct {
+	Success string    `json:"success"`
+	PurgeAt time.Time `json:"purge_at"`
+}
+
+// AccountExportManifest describes the files in a data export archive
+type AccountExportManifest struct {
+	UserID      uuid.UUID `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
+
+// AccountSecurityExport summarises how the account signs in, without secrets
+type AccountSecurityExport struct {
+	Role                   models.RoleName `json:"role"`
+	HasPassword            bool            `json:"has_password"`
+	TOTPEnabled            bool            `json:"totp_enabled"`
+	RecoveryCodesRemaining int64           `json:"recovery_codes_remaining"`
+	SuspendedAt            *time.Time      `json:"suspended_at,omitempty"`
+}
+
+// LinkedAccountExport is a linked OAuth provider account, without its tokens
+type LinkedAccountExport struct {
+	Provider    models.OAuthProvider `json:"provider"`
+	ProviderUID string               `json:"provider_uid"`
+	Email       string               `json:"email,omitempty"`
+	AvatarURL   string               `json:"avatar_url,omitempty"`
+	LinkedAt    time.Time            `json:"linked_at"`
+}
+
+func ToLinkedAccountExport(account models.OAuthAccount) LinkedAccountExport {
+	return LinkedAccountExport{
+		Provider:    account.Provider,
+		ProviderUID: account.ProviderUID,
+		Email:       account.Email,
+		AvatarURL:   account.AvatarURL,
+		LinkedAt:    account.CreatedAt,
+	}
+}
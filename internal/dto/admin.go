@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"build-in-public/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AdminUserResponse struct {
+	ID              uuid.UUID       `json:"id"`
+	FirstName       string          `json:"first_name"`
+	LastName        *string         `json:"last_name,omitempty"`
+	Email           string          `json:"email"`
+	EmailVerified   bool            `json:"email_verified"`
+	Role            models.RoleName `json:"role"`
+	CollegeID       *uuid.UUID      `json:"college_id,omitempty"`
+	Suspended       bool            `json:"suspended"`
+	SuspendedAt     *time.Time      `json:"suspended_at,omitempty"`
+	SuspendedReason string          `json:"suspended_reason,omitempty"`
+	LockedUntil     *time.Time      `json:"locked_until,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+}
+
+type AdminUserListResponse struct {
+	Users    []AdminUserResponse `json:"users"`
+	Total    int64               `json:"total"`
+	Page     int                 `json:"page"`
+	PageSize int                 `json:"page_size"`
+}
+
+func ToAdminUserResponse(user models.User) AdminUserResponse {
+	return AdminUserResponse{
+		ID:              user.ID,
+		FirstName:       user.FirstName,
+		LastName:        user.LastName,
+		Email:           user.Email,
+		EmailVerified:   user.EmailVerified,
+		Role:            user.Role,
+		CollegeID:       user.CollegeID,
+		Suspended:       user.SuspendedAt != nil,
+		SuspendedAt:     user.SuspendedAt,
+		SuspendedReason: user.SuspendedReason,
+		LockedUntil:     user.LockedUntil,
+		CreatedAt:       user.CreatedAt,
+	}
+}
+
+type ImpersonationResponse struct {
+	Success   string    `json:"success"`
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
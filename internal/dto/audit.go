@@ -0,0 +1,41 @@
+package dto
+
+import (
+	"build-in-public/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type AuditEventResponse struct {
+	ID        uuid.UUID          `json:"id"`
+	UserID    *uuid.UUID         `json:"user_id,omitempty"`
+	ActorID   *uuid.UUID         `json:"actor_id,omitempty"`
+	Action    models.AuditAction `json:"action"`
+	IPAddress string             `json:"ip_address"`
+	UserAgent string             `json:"user_agent"`
+	Device    string             `json:"device"`
+	Metadata  map[string]any     `json:"metadata,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type AuditEventListResponse struct {
+	Events   []AuditEventResponse `json:"events"`
+	Total    int64                `json:"total"`
+	Page     int                  `json:"page"`
+	PageSize int                  `json:"page_size"`
+}
+
+func ToAuditEventResponse(event models.AuditEvent, device string) AuditEventResponse {
+	return AuditEventResponse{
+		ID:        event.ID,
+		UserID:    event.UserID,
+		ActorID:   event.ActorID,
+		Action:    event.Action,
+		IPAddress: event.IPAddress,
+		UserAgent: event.UserAgent,
+		Device:    device,
+		Metadata:  event.Metadata,
+		CreatedAt: event.CreatedAt,
+	}
+}
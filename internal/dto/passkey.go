@@ -0,0 +1,26 @@
+package dto
+
+import (
+	"build-in-public/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type PasskeyResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Synced     bool       `json:"synced"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func ToPasskeyResponse(credential models.WebAuthnCredential) PasskeyResponse {
+	return PasskeyResponse{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		Synced:     credential.BackupState,
+		LastUsedAt: credential.LastUsedAt,
+		CreatedAt:  credential.CreatedAt,
+	}
+}
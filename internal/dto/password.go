@@ -0,0 +1,12 @@
+package dto
+
+type PasswordViolation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PasswordPolicyErrorResponse lists every password rule a request broke
+type PasswordPolicyErrorResponse struct {
+	Error      string              `json:"error"`
+	Violations []PasswordViolation `json:"violations"`
+}
@@ -7,3 +7,7 @@ type ErrorResponse struct {
 type SuccessResponse struct {
 	Success string `json:"success"`
 }
+
+type CSRFTokenResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"build-in-public/internal/models"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type SessionResponse struct {
+	ID           uuid.UUID `json:"id"`
+	IPAddress    string    `json:"ip_address"`
+	UserAgent    string    `json:"user_agent"`
+	DeviceLabel  string    `json:"device_label"`
+	Current      bool      `json:"current"`
+	Impersonated bool      `json:"impersonated"`
+	LastSeenAt   time.Time `json:"last_seen_at"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func ToSessionResponse(session models.Session, currentID uuid.UUID) SessionResponse {
+	return SessionResponse{
+		ID:           session.ID,
+		IPAddress:    session.IPAddress,
+		UserAgent:    session.UserAgent,
+		DeviceLabel:  session.DeviceLabel,
+		Current:      session.ID == currentID,
+		Impersonated: session.ImpersonatorID != nil,
+		LastSeenAt:   session.LastSeenAt,
+		ExpiresAt:    session.ExpiresAt,
+		CreatedAt:    session.CreatedAt,
+	}
+}
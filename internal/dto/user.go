@@ -14,7 +14,8 @@ type SocialAccountResponse struct {
 }
 
 type OAuthProviderResponse struct {
-	Provider string `json:"provider"`
+	Provider       string `json:"provider"`
+	ReauthRequired bool   `json:"reauth_required"`
 }
 
 type CollegeResponse struct {
@@ -59,7 +60,8 @@ func ToUserResponse(user models.User) UserResponse {
 	oauthProviders := make([]OAuthProviderResponse, 0, len(user.OAuthAccounts))
 	for _, acc := range user.OAuthAccounts {
 		oauthProviders = append(oauthProviders, OAuthProviderResponse{
-			Provider: string(acc.Provider),
+			Provider:       string(acc.Provider),
+			ReauthRequired: acc.ReauthRequired,
 		})
 	}
 
@@ -95,3 +97,8 @@ func ToUserResponse(user models.User) UserResponse {
 		UpdatedAt:       user.UpdatedAt,
 	}
 }
+
+type PhoneResponse struct {
+	Phone         string `json:"phone"`
+	PhoneVerified bool   `json:"phone_verified"`
+}
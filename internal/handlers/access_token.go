@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CreateAccessTokenRequest struct {
+	Name   string         `json:"name" binding:"required,max=100"`
+	Scopes []models.Scope `json:"scopes" binding:"required,min=1"`
+	// Days until the token expires; omit or 0 for a token that never expires
+	ExpiresInDays int `json:"expiresInDays" binding:"min=0,max=365"`
+}
+
+// ListAccessTokens godoc
+// @Summary      List personal access tokens
+// @Description  Returns the logged-in user's personal access tokens, newest first. Token values are never returned again
+// @Tags         Access Tokens
+// @Produce      json
+// @Success      200 {array}  dto.AccessTokenResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/tokens [get]
+func ListAccessTokens(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var tokens []models.PersonalAccessToken
+	if err := config.DB.Where("user_id = ?", user.ID).
+		Order("created_at DESC").
+		Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to load access tokens",
+		})
+		return
+	}
+
+	response := make([]dto.AccessTokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		response = append(response, dto.ToAccessTokenResponse(t))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateAccessToken godoc
+// @Summary      Create a personal access token
+// @Description  Issues a token for the CLI and scripts, sent as "Authorization: Bearer <token>". The token is only shown in this response
+// @Tags         Access Tokens
+// @Accept       json
+// @Produce      json
+// @Param        request body CreateAccessTokenRequest true "Create Access Token Request"
+// @Success      201 {object} dto.CreatedAccessTokenResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/tokens [post]
+func CreateAccessToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req CreateAccessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	scopes := make([]models.Scope, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		if !services.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: "Unknown scope: " + string(scope),
+			})
+			return
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	raw, token, err := services.CreatePersonalAccessToken(user.ID, req.Name, scopes, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to create access token",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditAccessTokenCreated, user.ID, map[string]any{
+		"token_id": token.ID,
+		"name":     token.Name,
+		"scopes":   token.Scopes,
+	})
+	c.JSON(http.StatusCreated, dto.CreatedAccessTokenResponse{
+		AccessTokenResponse: dto.ToAccessTokenResponse(*token),
+		Token:               raw,
+	})
+}
+
+// DeleteAccessToken godoc
+// @Summary      Revoke a personal access token
+// @Description  Deletes one of the logged-in user's personal access tokens; it stops working immediately
+// @Tags         Access Tokens
+// @Produce      json
+// @Param        id path string true "Token ID"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /users/me/tokens/{id} [delete]
+func DeleteAccessToken(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid token id",
+		})
+		return
+	}
+
+	result := config.DB.Delete(&models.PersonalAccessToken{}, "id = ? AND user_id = ?", id, user.ID)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to revoke access token",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error: "Access token not found",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditAccessTokenRevoked, user.ID, map[string]any{"token_id": id})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Access token revoked",
+	})
+}
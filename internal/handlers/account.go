@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// exportLimiter keeps repeated exports from loading the database
+var exportLimiter = services.NewRateLimiter("account-export-user", 5, time.Hour)
+
+type DeleteAccountRequest struct {
+	Password     string `json:"password"`
+	ConfirmEmail string `json:"confirmEmail"`
+}
+
+type RestoreAccountRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ExportAccount godoc
+// @Summary      Export account data
+// @Description  Downloads a ZIP archive of JSON files with everything stored about the logged-in user: profile, linked accounts, sessions, passkeys, access tokens and security events. Secrets such as password hashes and tokens are left out
+// @Tags         Users
+// @Produce      application/zip
+// @Success      200 {file} file
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      429 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/export [get]
+func ExportAccount(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if allowed, retryAfter := exportLimiter.Allow(user.ID.String()); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+
+	files, err := collectAccountExport(user)
+	if err != nil {
+		log.Printf("failed to collect account export: %v", err)
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to export account data",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditDataExported, user.ID, nil)
+
+	filename := "account-export-" + time.Now().UTC().Format("20060102") + ".zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	archive := zip.NewWriter(c.Writer)
+	for _, f := range files {
+		w, err := archive.Create(f.name)
+		if err != nil {
+			log.Printf("failed to write account export: %v", err)
+			return
+		}
+		if _, err := w.Write(f.data); err != nil {
+			log.Printf("failed to write account export: %v", err)
+			return
+		}
+	}
+	if err := archive.Close(); err != nil {
+		log.Printf("failed to write account export: %v", err)
+	}
+}
+
+// accountPendingDeletion writes a 409 for an email held by a deleted account
+// that can still be restored
+func accountPendingDeletion(c *gin.Context, purgeAt time.Time) {
+	c.JSON(http.StatusConflict, dto.ErrorResponse{
+		Error: "An account with this email is pending deletion. Restore it with the link we emailed, or sign up again after " + purgeAt.Format("2 January 2006"),
+	})
+}
+
+// DeleteAccount godoc
+// @Summary      Delete account
+// @Description  Deactivates the logged-in user's account, signs out every session and wipes all tokens. The account is permanently deleted after the grace period; until then it can be restored with the link emailed to the user. Accounts with a password must confirm it, others must confirm their email address
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body DeleteAccountRequest true "Delete Account Request"
+// @Success      200 {object} dto.AccountDeletionResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me [delete]
+func DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Password != nil {
+		if req.Password == "" {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: "Password is required",
+			})
+			return
+		}
+		if !services.VerifyPassword(*user.Password, req.Password) {
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error: "Password is incorrect",
+			})
+			return
+		}
+	} else if req.ConfirmEmail != user.Email {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Type your email address to confirm",
+		})
+		return
+	}
+
+	token, purgeAt, err := services.ScheduleAccountDeletion(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to delete account",
+		})
+		return
+	}
+
+	if err := services.SendAccountDeletionEmail(c.Request.Context(), user, token, purgeAt); err != nil {
+		log.Printf("failed to send account deletion email: %v", err)
+	}
+
+	services.RecordAudit(c, models.AuditAccountDeleted, user.ID, map[string]any{"purge_at": purgeAt})
+	services.ClearSessionCookie(c)
+	c.JSON(http.StatusOK, dto.AccountDeletionResponse{
+		Success: "Account scheduled for deletion",
+		PurgeAt: purgeAt,
+	})
+}
+
+// RestoreAccount godoc
+// @Summary      Restore a deleted account
+// @Description  Cancels a pending account deletion using the emailed restore token. The user then signs in again
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body RestoreAccountRequest true "Restore Account Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/account/restore [post]
+func RestoreAccount(c *gin.Context) {
+	var req RestoreAccountRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	user, err := services.RestoreAccount(req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidToken) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Invalid or expired restore token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to restore account"})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditAccountRestored, user.ID, nil)
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Account restored, you can sign in again",
+	})
+}
+
+type exportFile struct {
+	name string
+	data []byte
+}
+
+// collectAccountExport gathers the user's data as named JSON documents
+func collectAccountExport(user models.User) ([]exportFile, error) {
+	db := config.DB
+
+	if err := db.Preload("Socials").Preload("College").Preload("OAuthAccounts").
+		First(&user, "id = ?", user.ID).Error; err != nil {
+		return nil, err
+	}
+
+	totpEnabled, err := services.MFAEnabled(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := services.RemainingRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var sessions []models.Session
+	if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	var passkeys []models.WebAuthnCredential
+	if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&passkeys).Error; err != nil {
+		return nil, err
+	}
+	var tokens []models.PersonalAccessToken
+	if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	var events []models.AuditEvent
+	if err := db.Where("user_id = ?", user.ID).Order("created_at").Find(&events).Error; err != nil {
+		return nil, err
+	}
+
+	linked := make([]dto.LinkedAccountExport, 0, len(user.OAuthAccounts))
+	for _, a := range user.OAuthAccounts {
+		linked = append(linked, dto.ToLinkedAccountExport(a))
+	}
+	sessionDocs := make([]dto.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		sessionDocs = append(sessionDocs, dto.ToSessionResponse(s, s.ID))
+	}
+	passkeyDocs := make([]dto.PasskeyResponse, 0, len(passkeys))
+	for _, p := range passkeys {
+		passkeyDocs = append(passkeyDocs, dto.ToPasskeyResponse(p))
+	}
+	tokenDocs := make([]dto.AccessTokenResponse, 0, len(tokens))
+	for _, t := range tokens {
+		tokenDocs = append(tokenDocs, dto.ToAccessTokenResponse(t))
+	}
+	eventDocs := make([]dto.AuditEventResponse, 0, len(events))
+	for _, e := range events {
+		eventDocs = append(eventDocs, dto.ToAuditEventResponse(e, services.DeviceLabel(e.UserAgent)))
+	}
+
+	documents := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", dto.ToUserResponse(user)},
+		{"security.json", dto.AccountSecurityExport{
+			Role:                   user.Role,
+			HasPassword:            user.Password != nil,
+			TOTPEnabled:            totpEnabled,
+			RecoveryCodesRemaining: remaining,
+			SuspendedAt:            user.SuspendedAt,
+		}},
+		{"linked_accounts.json", linked},
+		{"sessions.json", sessionDocs},
+		{"passkeys.json", passkeyDocs},
+		{"access_tokens.json", tokenDocs},
+		{"security_events.json", eventDocs},
+	}
+
+	manifest := dto.AccountExportManifest{UserID: user.ID, GeneratedAt: time.Now().UTC()}
+	files := make([]exportFile, 0, len(documents)+1)
+	for _, d := range documents {
+		data, err := json.MarshalIndent(d.v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, exportFile{name: d.name, data: data})
+		manifest.Files = append(manifest.Files, d.name)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]exportFile{{name: "manifest.json", data: data}}, files...), nil
+}
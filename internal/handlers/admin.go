@@ -0,0 +1,309 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultAdminPageSize = 50
+	maxAdminPageSize     = 200
+)
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type AssignRoleRequest struct {
+	Role models.RoleName `json:"role" binding:"required"`
+}
+
+// AdminListUsers godoc
+// @Summary      List users
+// @Description  Paginated user search for the admin area. College admins only see members of their own college
+// @Tags         Admin
+// @Produce      json
+// @Param        q         query string false "Search by email or name"
+// @Param        role      query string false "Filter by role"
+// @Param        suspended query bool   false "Filter by suspension"
+// @Param        page      query int    false "Page number, from 1"
+// @Param        page_size query int    false "Results per page, up to 200"
+// @Success      200 {object} dto.AdminUserListResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /admin/users [get]
+func AdminListUsers(c *gin.Context) {
+	actor, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := pageParams(c)
+
+	query := config.DB.Model(&models.User{})
+	if actor.Role == models.RoleCollegeAdmin {
+		if actor.CollegeID == nil {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{
+				Error: "You are not assigned to a college",
+			})
+			return
+		}
+		query = query.Where("college_id = ?", *actor.CollegeID)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("LOWER(email) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ?", like, like, like)
+	}
+	if role := c.Query("role"); role != "" {
+		query = query.Where("role = ?", role)
+	}
+	if suspended, err := strconv.ParseBool(c.Query("suspended")); err == nil {
+		if suspended {
+			query = query.Where("suspended_at IS NOT NULL")
+		} else {
+			query = query.Where("suspended_at IS NULL")
+		}
+	}
+
+	// Reusable for both the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to load users",
+		})
+		return
+	}
+
+	var users []models.User
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to load users",
+		})
+		return
+	}
+
+	response := dto.AdminUserListResponse{
+		Users:    make([]dto.AdminUserResponse, 0, len(users)),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for _, u := range users {
+		response.Users = append(response.Users, dto.ToAdminUserResponse(u))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AdminSuspendUser godoc
+// @Summary      Suspend a user
+// @Description  Blocks the user from signing in and ends all of their sessions. Only users below the caller's role can be suspended
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id      path string             true "User ID"
+// @Param        request body SuspendUserRequest true "Suspend User Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /admin/users/{id}/suspend [post]
+func AdminSuspendUser(c *gin.Context) {
+	actor, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	target, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var req SuspendUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if !services.Outranks(actor.Role, target.Role) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error: "You can only suspend users below your role",
+		})
+		return
+	}
+
+	if err := services.SuspendUser(actor.ID, target.ID, req.Reason); err != nil {
+		adminActionError(c, err, "Failed to suspend user")
+		return
+	}
+
+	services.RecordAudit(c, models.AuditUserSuspended, target.ID, map[string]any{"reason": req.Reason})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "User suspended",
+	})
+}
+
+// AdminUnsuspendUser godoc
+// @Summary      Reinstate a user
+// @Description  Lifts a suspension so the user can sign in again. Only users below the caller's role can be reinstated
+// @Tags         Admin
+// @Produce      json
+// @Param        id path string true "User ID"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /admin/users/{id}/unsuspend [post]
+func AdminUnsuspendUser(c *gin.Context) {
+	actor, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	target, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	if !services.Outranks(actor.Role, target.Role) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error: "You can only reinstate users below your role",
+		})
+		return
+	}
+
+	if err := services.UnsuspendUser(actor.ID, target.ID); err != nil {
+		adminActionError(c, err, "Failed to reinstate user")
+		return
+	}
+
+	services.RecordAudit(c, models.AuditUserUnsuspended, target.ID, nil)
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "User reinstated",
+	})
+}
+
+// AdminAssignRole godoc
+// @Summary      Change a user's role
+// @Description  Assigns one of user, moderator, college-admin or admin. The target must rank below the caller, and the new role too, so no one can change their own or a peer's role or promote anyone to their own level
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id      path string            true "User ID"
+// @Param        request body AssignRoleRequest true "Assign Role Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /admin/users/{id}/role [put]
+func AdminAssignRole(c *gin.Context) {
+	actor, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	target, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var req AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if !services.Outranks(actor.Role, target.Role) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error: "You can only change the role of users below your role",
+		})
+		return
+	}
+	if !services.Outranks(actor.Role, req.Role) {
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{
+			Error: "You can only assign roles below your own",
+		})
+		return
+	}
+
+	if err := services.AssignRole(actor.ID, target.ID, req.Role); err != nil {
+		adminActionError(c, err, "Failed to assign role")
+		return
+	}
+
+	services.RecordAudit(c, models.AuditRoleAssigned, target.ID, map[string]any{
+		"from": target.Role,
+		"to":   req.Role,
+	})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Role updated",
+	})
+}
+
+// pageParams reads the page and page_size query params, clamped to sane values
+func pageParams(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	page = max(page, 1)
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultAdminPageSize)))
+	if pageSize < 1 || pageSize > maxAdminPageSize {
+		pageSize = defaultAdminPageSize
+	}
+	return page, pageSize
+}
+
+// adminTargetUser loads the user named by the :id path param
+func adminTargetUser(c *gin.Context) (models.User, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid user id",
+		})
+		return models.User{}, false
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error: "User not found",
+		})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// adminActionError maps service errors from admin actions to responses
+func adminActionError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrCannotModifySelf):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "You cannot do this to your own account"})
+	case errors.Is(err, services.ErrCannotImpersonate):
+		c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: "Staff and suspended accounts cannot be impersonated"})
+	case errors.Is(err, services.ErrUnknownRole):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Unknown role"})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "User not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: fallback})
+	}
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ListSecurityEvents godoc
+// @Summary      Recent security activity
+// @Description  Returns the audit log of the logged-in user's account (logins, failed logins, password and session changes), newest first
+// @Tags         Audit
+// @Produce      json
+// @Param        page      query int false "Page number, from 1"
+// @Param        page_size query int false "Results per page, up to 200"
+// @Success      200 {object} dto.AuditEventListResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/security-events [get]
+func ListSecurityEvents(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Model(&models.AuditEvent{}).Where("user_id = ?", user.ID)
+	respondWithAuditEvents(c, query)
+}
+
+// AdminListAuditEvents godoc
+// @Summary      Search the audit log
+// @Description  Filterable view of every audit event, newest first
+// @Tags         Admin
+// @Produce      json
+// @Param        user_id   query string false "Account the event is about"
+// @Param        actor_id  query string false "Who performed the action"
+// @Param        action    query string false "Action, e.g. login_failed"
+// @Param        ip        query string false "Client IP address"
+// @Param        from      query string false "Earliest time, RFC 3339"
+// @Param        to        query string false "Latest time, RFC 3339"
+// @Param        page      query int    false "Page number, from 1"
+// @Param        page_size query int    false "Results per page, up to 200"
+// @Success      200 {object} dto.AuditEventListResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /admin/audit-events [get]
+func AdminListAuditEvents(c *gin.Context) {
+	query := config.DB.Model(&models.AuditEvent{})
+
+	for _, param := range []string{"user_id", "actor_id"} {
+		if raw := c.Query(param); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+					Error: "Invalid " + param,
+				})
+				return
+			}
+			query = query.Where(param+" = ?", id)
+		}
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+	for param, condition := range map[string]string{"from": "created_at >= ?", "to": "created_at <= ?"} {
+		if raw := c.Query(param); raw != "" {
+			t, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+					Error: "Invalid " + param + ", expected RFC 3339",
+				})
+				return
+			}
+			query = query.Where(condition, t)
+		}
+	}
+
+	respondWithAuditEvents(c, query)
+}
+
+// respondWithAuditEvents writes one page of the events matched by query
+func respondWithAuditEvents(c *gin.Context, query *gorm.DB) {
+	page, pageSize := pageParams(c)
+	// Reusable for both the count and the page query
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to load audit events",
+		})
+		return
+	}
+
+	var events []models.AuditEvent
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to load audit events",
+		})
+		return
+	}
+
+	response := dto.AuditEventListResponse{
+		Events:   make([]dto.AuditEventResponse, 0, len(events)),
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	for _, e := range events {
+		response.Events = append(response.Events, dto.ToAuditEventResponse(e, services.DeviceLabel(e.UserAgent)))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -1,22 +1,29 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"build-in-public/internal/config"
 	"build-in-public/internal/dto"
 	"build-in-public/internal/models"
+	"build-in-public/internal/services"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/google/uuid"
 )
 
+// loginEmailLimiter caps attempts per account; per-IP limits are applied by
+// middleware on the route
+var loginEmailLimiter = services.NewRateLimiter("login-email", 20, 15*time.Minute)
+
 type SignupRequest struct {
 	FirstName string `json:"firstName" binding:"required"`
 	LastName  string `json:"lastName" binding:"required"`
 	Email     string `json:"email" binding:"required,email"`
-	Password  string `json:"password" binding:"required,min=8"`
+	Password  string `json:"password" binding:"required"`
 	Phone     string `json:"phoneNo" binding:"max=20"`
 	LinkedIn  string `json:"linkedIn" binding:"max=255"`
 }
@@ -33,8 +40,8 @@ type LoginRequest struct {
 // @Produce      json
 // @Param        request body SignupRequest true "Signup Request"
 // @Success      201 {object} dto.UserResponse
-// @Failure      400 {object} dto.ErrorResponse
-// @Failure      409 {object} dto.ErrorResponse
+// @Failure      400 {object} dto.PasswordPolicyErrorResponse "Invalid input, or violations of the password policy"
+// @Failure      409 {object} dto.ErrorResponse "Email in use, or held by an account pending deletion"
 // @Failure      500 {object} dto.ErrorResponse
 // @Router       /auth/signup [post]
 func Signup(c *gin.Context) {
@@ -49,11 +56,29 @@ func Signup(c *gin.Context) {
 		return
 	}
 
+	if !checkPasswordPolicy(c, req.Password, models.User{
+		Email:     req.Email,
+		FirstName: req.FirstName,
+		LastName:  &req.LastName,
+	}) {
+		return
+	}
+
+	// Phone is optional at signup and verified later from the profile
+	var phone *string
+	if req.Phone != "" {
+		normalized, err := services.NormalizePhone(req.Phone)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: "Invalid phone number, use international format such as +14155550100",
+			})
+			return
+		}
+		phone = &normalized
+	}
+
 	// 2. Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword(
-		[]byte(req.Password),
-		bcrypt.DefaultCost,
-	)
+	hashed, err := services.HashPassword(req.Password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 			Error: "Failed to hash password",
@@ -62,47 +87,40 @@ func Signup(c *gin.Context) {
 	}
 
 	// 3. Create user
-	hashed := string(hashedPassword)
-
 	user := models.User{
 		FirstName:     req.FirstName,
 		LastName:      &req.LastName,
 		Email:         req.Email,
 		Password:      &hashed,
-		Phone:         nil,
+		Phone:         phone,
 		EmailVerified: false,
 		PhoneVerified: false,
 	}
 	if err := config.DB.Create(&user).Error; err != nil {
+		if purgeAt, pending := services.PendingDeletion(req.Email); pending {
+			accountPendingDeletion(c, purgeAt)
+			return
+		}
 		c.JSON(http.StatusConflict, dto.ErrorResponse{
 			Error: "Email already exists",
 		})
 		return
 	}
-	// Create session
-	session := models.Session{
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+
+	if err := sendVerificationEmail(c, user); err != nil {
+		log.Printf("failed to send verification email: %v", err)
 	}
 
-	if err := config.DB.Create(&session).Error; err != nil {
+	services.RecordAudit(c, models.AuditSignup, user.ID, map[string]any{"method": "password"})
+
+	// Create session
+	if _, err := services.IssueSession(c, user.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 			Error: "Failed to create a session",
 		})
 		return
 	}
 
-	// Set cookie with SameSite=Lax for localhost compatibility
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie(
-		"session_id",
-		session.ID.String(),
-		60*60*24*7, // 7 days
-		"/",
-		"",
-		false, // true in production (HTTPS)
-		true,  // HttpOnly
-	)
 	c.JSON(http.StatusCreated, dto.SuccessResponse{Success: "User created successfully"})
 }
 
@@ -113,9 +131,11 @@ func Signup(c *gin.Context) {
 // @Accept       json
 // @Produce      json
 // @Param        request body LoginRequest true "Login Request"
-// @Success      200 {object} dto.SuccessResponse
+// @Success      200 {object} dto.SuccessResponse "Logged in, or dto.MFAChallengeResponse when a second factor is required"
 // @Failure      400 {object} dto.ErrorResponse
 // @Failure      401 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse "Account suspended"
+// @Failure      429 {object} dto.ErrorResponse "Rate limited, see Retry-After"
 // @Failure      500 {object} dto.ErrorResponse
 // @Router       /auth/login [post]
 func Login(c *gin.Context) {
@@ -128,58 +148,135 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	user, ok := authenticatePassword(c, req.Email, req.Password)
+	if !ok {
+		return
+	}
+
+	completeLogin(c, user, "password")
+}
+
+// completeLogin finishes a first factor login answered with JSON: users with a
+// second factor get a challenge, everyone else a session. method names the
+// first factor for the audit log.
+func completeLogin(c *gin.Context, user models.User, method string) {
+	// Users with a second factor get a challenge instead of a session
+	challenge, err := beginMFAChallenge(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to start two-factor login",
+		})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, dto.MFAChallengeResponse{
+			MFARequired: true,
+			ChallengeID: challenge.ID,
+			ExpiresAt:   challenge.ExpiresAt,
+		})
+		return
+	}
+
+	// Create session
+	if _, err := services.IssueSession(c, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to create a session",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditLogin, user.ID, map[string]any{"method": method})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Logged In",
+	})
+}
+
+// authenticatePassword checks an email and password, applying rate limits,
+// lockout and suspension. It writes the error response and returns false when
+// the user may not sign in.
+func authenticatePassword(c *gin.Context, email, password string) (models.User, bool) {
+	// Spread-out guessing against one account from many IPs
+	if allowed, retryAfter := loginEmailLimiter.Allow(strings.ToLower(email)); !allowed {
+		tooManyRequests(c, retryAfter)
+		return models.User{}, false
+	}
+
 	var user models.User
-	if err := config.DB.Where("email = ?", req.Email).First(&user).Error; err != nil {
+	if err := config.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		services.SpendPasswordCheck(password)
+		services.RecordAudit(c, models.AuditLoginFailed, uuid.Nil, map[string]any{"reason": "unknown_email"})
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error: "Invalid Credentials",
 		})
-		return
+		return models.User{}, false
+	}
+
+	// A locked account answers exactly like an unknown email, and takes as
+	// long, so the lockout cannot be used to find out which addresses have accounts
+	if locked, _ := services.AccountLocked(&user, time.Now()); locked {
+		services.SpendPasswordCheck(password)
+		services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{"reason": "locked"})
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "Invalid Credentials",
+		})
+		return models.User{}, false
 	}
 
 	if user.Password == nil {
+		services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{"reason": "no_password"})
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error: "Use OAuth login",
 		})
-		return
+		return models.User{}, false
 	}
 
-	if err := bcrypt.CompareHashAndPassword(
-		[]byte(*user.Password),
-		[]byte(req.Password),
-	); err != nil {
+	if !services.VerifyPassword(*user.Password, password) {
+		services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{"reason": "bad_password"})
+
+		failures, lockout, err := services.RecordFailedLogin(user.ID)
+		if err != nil {
+			log.Printf("failed to record failed login: %v", err)
+		}
+		if lockout > 0 {
+			services.RecordAudit(c, models.AuditAccountLocked, user.ID, map[string]any{
+				"failures":        failures,
+				"lockout_seconds": int(lockout.Seconds()),
+			})
+		}
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error: "Invalid Credentials",
 		})
-		return
+		return models.User{}, false
 	}
 
-	// Create session
-	session := models.Session{
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+	if user.SuspendedAt != nil {
+		services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{"reason": "suspended"})
+		accountSuspended(c)
+		return models.User{}, false
 	}
 
-	if err := config.DB.Create(&session).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Error: "Failed to create a session",
-		})
-		return
+	if user.FailedLoginCount > 0 || user.LockedUntil != nil {
+		services.ResetFailedLogins(config.DB, user.ID)
 	}
 
-	// Set cookie with SameSite=Lax for localhost compatibility
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie(
-		"session_id",
-		session.ID.String(),
-		60*60*24*7, // 7 days
-		"/",
-		"",
-		false, // true in production (HTTPS)
-		true,  // HttpOnly
-	)
+	// The plain password is only known now, so this is when an outdated hash can be upgraded
+	if services.PasswordNeedsRehash(*user.Password) {
+		if hashed, err := services.HashPassword(password); err != nil {
+			log.Printf("failed to rehash password: %v", err)
+		} else if err := config.DB.Model(&models.User{}).
+			Where("id = ? AND password = ?", user.ID, *user.Password).
+			Update("password", hashed).Error; err != nil {
+			log.Printf("failed to store rehashed password: %v", err)
+		}
+	}
 
-	c.JSON(http.StatusOK, dto.SuccessResponse{
-		Success: "Logged In",
+	return user, true
+}
+
+// accountSuspended writes a 403 for a user suspended by an admin
+func accountSuspended(c *gin.Context) {
+	c.JSON(http.StatusForbidden, dto.ErrorResponse{
+		Error: "Your account has been suspended",
 	})
 }
 
@@ -190,11 +287,15 @@ func Login(c *gin.Context) {
 // @Success      200 {object} dto.SuccessResponse
 // @Router       /auth/logout [post]
 func Logout(c *gin.Context) {
-	sessionID, _ := c.Cookie("session_id")
-	config.DB.Delete(&models.Session{}, "id = ?", sessionID)
+	sessionID, _ := c.Cookie(services.SessionCookieName)
+
+	var session models.Session
+	if err := config.DB.First(&session, "id = ?", sessionID).Error; err == nil {
+		config.DB.Delete(&session)
+		services.RecordAudit(c, models.AuditLogout, session.UserID, nil)
+	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("session_id", "", -1, "/", "", false, true)
+	services.ClearSessionCookie(c)
 	c.JSON(http.StatusOK, dto.SuccessResponse{
 		Success: "Logged Out",
 	})
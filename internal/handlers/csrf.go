@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"net/http"
+
+	"build-in-public/internal/dto"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetCSRFToken godoc
+// @Summary      Get a CSRF token
+// @Description  Sets the csrf_token cookie and returns its value, to be sent back in the X-CSRF-Token header on POST, PUT and DELETE requests
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {object} dto.CSRFTokenResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/csrf [get]
+func GetCSRFToken(c *gin.Context) {
+	token, err := services.CSRFToken(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to issue CSRF token",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, dto.CSRFTokenResponse{
+		CSRFToken: token,
+	})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImpersonateUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// AdminImpersonateUser godoc
+// @Summary      Impersonate a user
+// @Description  Switches the admin's browser to a short-lived session of the user, to see exactly what they see. Responses carry an X-Impersonated-By header, and account security actions such as changing the password, MFA or deleting the account are blocked. Staff and suspended accounts cannot be impersonated
+// @Tags         Admin
+// @Accept       json
+// @Produce      json
+// @Param        id      path string                 true "User ID"
+// @Param        request body ImpersonateUserRequest true "Impersonate User Request"
+// @Success      200 {object} dto.ImpersonationResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /admin/users/{id}/impersonate [post]
+func AdminImpersonateUser(c *gin.Context) {
+	actor, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	adminSession, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	target, ok := adminTargetUser(c)
+	if !ok {
+		return
+	}
+
+	var req ImpersonateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	session, err := services.StartImpersonation(c, actor, adminSession, target)
+	if err != nil {
+		adminActionError(c, err, "Failed to start impersonation")
+		return
+	}
+
+	services.RecordAudit(c, models.AuditImpersonationStarted, target.ID, map[string]any{
+		"reason":     req.Reason,
+		"session_id": session.ID,
+		"expires_at": session.ExpiresAt,
+	})
+	c.JSON(http.StatusOK, dto.ImpersonationResponse{
+		Success:   "Impersonating user",
+		UserID:    target.ID,
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+// StopImpersonation godoc
+// @Summary      Stop impersonating
+// @Description  Ends the current impersonation session and switches back to the admin's own session, if it is still valid
+// @Tags         Admin
+// @Produce      json
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/impersonation/stop [post]
+func StopImpersonation(c *gin.Context) {
+	session, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	if _, err := services.StopImpersonation(c, session); err != nil {
+		if errors.Is(err, services.ErrNotImpersonating) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Not impersonating a user"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to stop impersonation"})
+		return
+	}
+
+	// The actor is still taken from the request context: the admin behind the session
+	services.RecordAudit(c, models.AuditImpersonationStopped, session.UserID, map[string]any{"session_id": session.ID})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Stopped impersonating",
+	})
+}
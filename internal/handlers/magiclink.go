@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const magicLinkTTL = 15 * time.Minute
+
+var (
+	magicLinkEmailLimiter = services.NewRateLimiter("magic-link-email", 3, 15*time.Minute)
+	magicLinkIPLimiter    = services.NewRateLimiter("magic-link-ip", 10, 15*time.Minute)
+)
+
+type MagicLinkRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// RequestMagicLink godoc
+// @Summary      Email a sign-in link
+// @Description  Sends a single-use passwordless sign-in link if the email belongs to an account. The response is identical either way
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body MagicLinkRequest true "Magic Link Request"
+// @Success      202 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      429 {object} dto.ErrorResponse
+// @Router       /auth/magic-link [post]
+func RequestMagicLink(c *gin.Context) {
+	var req MagicLinkRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	email := strings.ToLower(req.Email)
+	if allowed, retryAfter := magicLinkIPLimiter.Allow(c.ClientIP()); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+	if allowed, retryAfter := magicLinkEmailLimiter.Allow(email); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+
+	go sendMagicLink(req.Email)
+
+	c.JSON(http.StatusAccepted, dto.SuccessResponse{
+		Success: "If an account exists for that email, a sign-in link has been sent",
+	})
+}
+
+type ConsumeMagicLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// MagicLinkLanding godoc
+// @Summary      Open an emailed sign-in link
+// @Description  Forwards links sent before sign-in moved to the frontend to its confirmation page, without spending the token. Mail scanners and link prefetchers can follow it safely
+// @Tags         Auth
+// @Param        token query string true "Magic link token"
+// @Success      307
+// @Router       /auth/magic-link/consume [get]
+func MagicLinkLanding(c *gin.Context) {
+	c.Redirect(http.StatusTemporaryRedirect, services.MagicLinkURL(c.Query("token")))
+}
+
+// ConsumeMagicLink godoc
+// @Summary      Sign in with an emailed link
+// @Description  Spends a magic link token and starts a session, or a two-factor challenge. The frontend posts the token from its confirmation page, so following the link alone signs no one in
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body ConsumeMagicLinkRequest true "Consume Magic Link Request"
+// @Success      200 {object} dto.SuccessResponse "Logged in, or dto.MFAChallengeResponse when a second factor is required"
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse "Account suspended"
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/magic-link/consume [post]
+func ConsumeMagicLink(c *gin.Context) {
+	var req ConsumeMagicLinkRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	token, err := services.ConsumeUserToken(req.Token, models.TokenMagicLink)
+	if err != nil {
+		if !errors.Is(err, services.ErrInvalidToken) {
+			log.Printf("failed to consume magic link: %v", err)
+		}
+		invalidMagicLink(c)
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", token.UserID).Error; err != nil {
+		invalidMagicLink(c)
+		return
+	}
+
+	// Receiving the link proves ownership of the address. Whoever signed up
+	// with it may not have owned it, so they lose every way to sign in.
+	if !user.EmailVerified {
+		claimed, err := services.ClaimUnverifiedAccount(user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "Failed to update user",
+			})
+			return
+		}
+		user.EmailVerified = true
+		if claimed {
+			user.Password = nil
+			services.RecordAudit(c, models.AuditSessionsRevoked, user.ID, map[string]any{
+				"reason": "unverified_account_claimed",
+			})
+		}
+	}
+
+	if user.SuspendedAt != nil {
+		services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{"method": "magic_link", "reason": "suspended"})
+		accountSuspended(c)
+		return
+	}
+
+	completeLogin(c, user, "magic_link")
+}
+
+func invalidMagicLink(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+		Error: "Invalid or expired sign-in link",
+	})
+}
+
+// sendMagicLink mails a sign-in link to the account with the given email, if any
+func sendMagicLink(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return
+	}
+
+	token, err := services.IssueUserToken(user.ID, models.TokenMagicLink, magicLinkTTL)
+	if err != nil {
+		log.Printf("failed to issue magic link token: %v", err)
+		return
+	}
+
+	if err := services.SendMagicLinkEmail(ctx, user, token); err != nil {
+		log.Printf("failed to send magic link email: %v", err)
+	}
+}
+
+// tooManyRequests writes a 429 response with a Retry-After header
+func tooManyRequests(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, dto.ErrorResponse{
+		Error: "Too many requests, please try again later",
+	})
+}
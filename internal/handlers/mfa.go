@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type ConfirmTOTPRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type VerifyMFARequest struct {
+	ChallengeID  string `json:"challengeId" binding:"required,uuid"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+// VerifyMFA godoc
+// @Summary      Complete two-factor login
+// @Description  Exchanges a pending MFA challenge and a TOTP or recovery code for a session
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body VerifyMFARequest true "Verify MFA Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/mfa/verify [post]
+func VerifyMFA(c *gin.Context) {
+	var req VerifyMFARequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	userID, err := services.CompleteMFAChallenge(uuid.MustParse(req.ChallengeID), req.Code, req.RecoveryCode, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			services.RecordAudit(c, models.AuditLoginFailed, userID, map[string]any{"reason": "bad_mfa_code"})
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Invalid verification code"})
+		case errors.Is(err, services.ErrMFAChallengeExpired):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Login attempt expired, please sign in again"})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to verify code"})
+		}
+		return
+	}
+
+	if _, err := services.IssueSession(c, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to create a session",
+		})
+		return
+	}
+
+	secondFactor := "totp"
+	if req.RecoveryCode != "" {
+		secondFactor = "recovery_code"
+	}
+	services.RecordAudit(c, models.AuditLogin, userID, map[string]any{"method": "mfa", "second_factor": secondFactor})
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Logged In",
+	})
+}
+
+// GetMFAStatus godoc
+// @Summary      Two-factor status
+// @Description  Reports whether the logged-in user has an authenticator enrolled and how many recovery codes remain
+// @Tags         MFA
+// @Produce      json
+// @Success      200 {object} dto.MFAStatusResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/mfa [get]
+func GetMFAStatus(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	enabled, err := services.MFAEnabled(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to load MFA status"})
+		return
+	}
+
+	var remaining int64
+	if enabled {
+		if remaining, err = services.RemainingRecoveryCodes(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to load MFA status"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, dto.MFAStatusResponse{
+		TOTPEnabled:            enabled,
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// BeginTOTPEnrolment godoc
+// @Summary      Start authenticator enrolment
+// @Description  Generates a TOTP secret and the otpauth URI to render as a QR code. It is inactive until confirmed
+// @Tags         MFA
+// @Produce      json
+// @Success      200 {object} dto.TOTPEnrolmentResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/mfa/totp [post]
+func BeginTOTPEnrolment(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	secret, err := services.BeginTOTPEnrolment(user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: "Two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to start enrolment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.TOTPEnrolmentResponse{
+		Secret:     secret,
+		OTPAuthURI: services.TOTPURI(secret, user.Email),
+	})
+}
+
+// ConfirmTOTPEnrolment godoc
+// @Summary      Confirm authenticator enrolment
+// @Description  Activates the pending authenticator with a valid code and returns one-time recovery codes. Other sessions are signed out
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Param        request body ConfirmTOTPRequest true "Confirm TOTP Request"
+// @Success      200 {object} dto.RecoveryCodesResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/mfa/totp/confirm [post]
+func ConfirmTOTPEnrolment(c *gin.Context) {
+	var req ConfirmTOTPRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	session, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	codes, err := services.ConfirmTOTPEnrolment(user.ID, req.Code, time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Invalid verification code"})
+		case errors.Is(err, services.ErrTOTPEnrolmentNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "No pending enrolment"})
+		case errors.Is(err, services.ErrMFAAlreadyEnabled):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: "Two-factor authentication is already enabled"})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to confirm enrolment"})
+		}
+		return
+	}
+
+	// Sessions created before enrolment never passed a second factor
+	if err := services.RevokeSessions(config.DB, user.ID, session.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to revoke sessions"})
+		return
+	}
+	if _, err := services.IssueSession(c, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to create a session"})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditMFAEnabled, user.ID, map[string]any{"factor": "totp"})
+	c.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP godoc
+// @Summary      Disable two-factor authentication
+// @Description  Removes the authenticator and recovery codes. Requires a current TOTP or recovery code
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Param        request body MFACodeRequest true "MFA Code Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/mfa/totp [delete]
+func DisableTOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !verifySecondFactor(c, user) {
+		return
+	}
+
+	if err := services.DisableTOTP(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to disable two-factor authentication"})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditMFADisabled, user.ID, map[string]any{"factor": "totp"})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes godoc
+// @Summary      Regenerate recovery codes
+// @Description  Replaces all recovery codes. Requires a current TOTP or recovery code
+// @Tags         MFA
+// @Accept       json
+// @Produce      json
+// @Param        request body MFACodeRequest true "MFA Code Request"
+// @Success      200 {object} dto.RecoveryCodesResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/mfa/recovery-codes [post]
+func RegenerateRecoveryCodes(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !verifySecondFactor(c, user) {
+		return
+	}
+
+	codes, err := services.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// verifySecondFactor binds an MFACodeRequest and checks it against the user's
+// enrolled factors, writing an error response on failure
+func verifySecondFactor(c *gin.Context, user models.User) bool {
+	var req MFACodeRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return false
+	}
+
+	var err error
+	switch {
+	case req.Code != "":
+		err = services.VerifyTOTP(user.ID, req.Code, time.Now())
+	case req.RecoveryCode != "":
+		err = services.UseRecoveryCode(user.ID, req.RecoveryCode, time.Now())
+	default:
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "A verification code is required"})
+		return false
+	}
+
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrMFANotEnabled):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Two-factor authentication is not enabled"})
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Invalid verification code"})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to verify code"})
+		}
+		return false
+	}
+
+	return true
+}
+
+// beginMFAChallenge returns a pending challenge if the user must pass a
+// second factor before getting a session, or nil if they have none enrolled
+func beginMFAChallenge(user models.User) (*models.MFAChallenge, error) {
+	enabled, err := services.MFAEnabled(user.ID)
+	if err != nil || !enabled {
+		return nil, err
+	}
+	return services.CreateMFAChallenge(user.ID, time.Now())
+}
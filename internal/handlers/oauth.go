@@ -1,10 +1,8 @@
 package handlers
 
 import (
-	"crypto/rand"
-	"encoding/base64"
 	"net/http"
-	"os"
+	"net/url"
 	"time"
 
 	"build-in-public/internal/config"
@@ -13,61 +11,88 @@ import (
 	"build-in-public/internal/services"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"golang.org/x/oauth2"
 )
 
-// generateStateToken generates a random state token for OAuth
-func generateStateToken() string {
-	b := make([]byte, 32)
-	rand.Read(b)
-	return base64.URLEncoding.EncodeToString(b)
-}
+const oauthStateCookieName = "oauth_state"
 
-// GoogleLogin godoc
-// @Summary      Initiate Google OAuth login
-// @Description  Redirects user to Google OAuth consent page
+// OAuthLogin godoc
+// @Summary      Initiate OAuth login
+// @Description  Redirects user to the provider's OAuth consent page
 // @Tags         OAuth
+// @Param        provider  path  string true  "Provider: google, github, linkedin, microsoft or a configured OIDC issuer"
+// @Param        return_to query string false "Frontend path to land on after login"
 // @Success      302
-// @Router       /auth/google [get]
-func GoogleLogin(c *gin.Context) {
-	state := generateStateToken()
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /auth/{provider} [get]
+func OAuthLogin(c *gin.Context) {
+	provider, ok := lookupProvider(c)
+	if !ok {
+		return
+	}
+
+	flow, err := services.StartOAuthFlow(provider.Name(), services.SafeReturnTo(c.Query("return_to")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to start OAuth login",
+		})
+		return
+	}
 
-	// Store state in session/cookie for verification (in production, use Redis or DB)
+	// The state cookie binds the flow to this browser; PKCE verifier and nonce stay server side
 	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("oauth_state", state, 600, "/", "", false, true) // 10 minutes
+	c.SetCookie(oauthStateCookieName, flow.State, 600, "/", "", false, true) // 10 minutes
 
-	url := services.OAuth.Google.AuthCodeURL(state)
+	url := provider.AuthCodeURL(flow.State, flow.AuthCodeOptions()...)
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-// GoogleCallback godoc
-// @Summary      Google OAuth callback
-// @Description  Handles the callback from Google OAuth
+// OAuthCallback godoc
+// @Summary      OAuth callback
+// @Description  Handles the callback from the provider's OAuth consent page
 // @Tags         OAuth
-// @Param        code  query string true "Authorization code"
-// @Param        state query string true "State token"
+// @Param        provider path  string true "Provider: google, github, linkedin, microsoft or a configured OIDC issuer"
+// @Param        code     query string true "Authorization code"
+// @Param        state    query string true "State token"
 // @Success      200 {object} dto.SuccessResponse
 // @Failure      400 {object} dto.ErrorResponse
 // @Failure      401 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse "Email in use, or held by an account pending deletion"
 // @Failure      500 {object} dto.ErrorResponse
-// @Router       /auth/google/callback [get]
-func GoogleCallback(c *gin.Context) {
-	// Verify state
+// @Router       /auth/{provider}/callback [get]
+func OAuthCallback(c *gin.Context) {
+	provider, ok := lookupProvider(c)
+	if !ok {
+		return
+	}
+
+	// Verify state against the browser cookie, then consume it server side
 	state := c.Query("state")
-	storedState, err := c.Cookie("oauth_state")
-	if err != nil || state != storedState {
+	storedState, _ := c.Cookie(oauthStateCookieName)
+
+	// Clear state cookie
+	c.SetCookie(oauthStateCookieName, "", -1, "/", "", false, true)
+
+	if !services.OAuthStateMatches(state, storedState) {
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error: "Invalid state token",
 		})
 		return
 	}
 
-	// Clear state cookie
-	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
+	flow, err := services.ConsumeOAuthState(provider.Name(), state)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "Invalid state token",
+		})
+		return
+	}
 
 	// Exchange code for token
 	code := c.Query("code")
-	token, err := services.OAuth.Google.Exchange(c.Request.Context(), code)
+	token, err := provider.Exchange(c.Request.Context(), code, oauth2.VerifierOption(flow.CodeVerifier))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
 			Error: "Failed to exchange token",
@@ -75,8 +100,9 @@ func GoogleCallback(c *gin.Context) {
 		return
 	}
 
-	// Get user info from Google
-	userInfo, err := services.GetGoogleUserInfo(c.Request.Context(), token)
+	// Get user info from the provider, binding OIDC ID tokens to our nonce
+	ctx := services.WithOIDCNonce(c.Request.Context(), flow.Nonce)
+	userInfo, err := provider.UserInfo(ctx, token)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 			Error: "Failed to get user info",
@@ -84,132 +110,126 @@ func GoogleCallback(c *gin.Context) {
 		return
 	}
 
+	if flow.LinkUserID != nil {
+		handleOAuthLink(c, *flow.LinkUserID, userInfo, token, provider.Name(), flow.ReturnTo)
+		return
+	}
+
 	// Handle user creation/login
-	handleOAuthLogin(c, userInfo, token, models.OAuthGoogle)
+	handleOAuthLogin(c, userInfo, token, provider.Name(), flow.ReturnTo)
 }
 
-// GitHubLogin godoc
-// @Summary      Initiate GitHub OAuth login
-// @Description  Redirects user to GitHub OAuth consent page
+// OAuthLink godoc
+// @Summary      Link an OAuth provider
+// @Description  Redirects the logged-in user to the provider's consent page; the callback attaches the provider account to them. Requires a verified email address
 // @Tags         OAuth
+// @Param        provider  path  string true  "Provider: google, github, linkedin, microsoft or a configured OIDC issuer"
+// @Param        return_to query string false "Frontend path to land on after linking"
 // @Success      302
-// @Router       /auth/github [get]
-func GitHubLogin(c *gin.Context) {
-	state := generateStateToken()
-
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("oauth_state", state, 600, "/", "", false, true)
-
-	url := services.OAuth.GitHub.AuthCodeURL(state)
-	c.Redirect(http.StatusTemporaryRedirect, url)
-}
-
-// GitHubCallback godoc
-// @Summary      GitHub OAuth callback
-// @Description  Handles the callback from GitHub OAuth
-// @Tags         OAuth
-// @Param        code  query string true "Authorization code"
-// @Param        state query string true "State token"
-// @Success      200 {object} dto.SuccessResponse
-// @Failure      400 {object} dto.ErrorResponse
 // @Failure      401 {object} dto.ErrorResponse
-// @Failure      500 {object} dto.ErrorResponse
-// @Router       /auth/github/callback [get]
-func GitHubCallback(c *gin.Context) {
-	// Verify state
-	state := c.Query("state")
-	storedState, err := c.Cookie("oauth_state")
-	if err != nil || state != storedState {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
-			Error: "Invalid state token",
-		})
+// @Failure      403 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /auth/{provider}/link [get]
+func OAuthLink(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
-	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
-
-	code := c.Query("code")
-	token, err := services.OAuth.GitHub.Exchange(c.Request.Context(), code)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			Error: "Failed to exchange token",
-		})
+	provider, ok := lookupProvider(c)
+	if !ok {
 		return
 	}
 
-	userInfo, err := services.GetGitHubUserInfo(c.Request.Context(), token)
+	flow, err := services.StartOAuthLinkFlow(provider.Name(), user.ID, services.SafeReturnTo(c.Query("return_to")))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Error: "Failed to get user info",
+			Error: "Failed to start OAuth linking",
 		})
 		return
 	}
 
-	handleOAuthLogin(c, userInfo, token, models.OAuthGithub)
-}
-
-// LinkedInLogin godoc
-// @Summary      Initiate LinkedIn OAuth login
-// @Description  Redirects user to LinkedIn OAuth consent page
-// @Tags         OAuth
-// @Success      302
-// @Router       /auth/linkedin [get]
-func LinkedInLogin(c *gin.Context) {
-	state := generateStateToken()
-
 	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("oauth_state", state, 600, "/", "", false, true)
+	c.SetCookie(oauthStateCookieName, flow.State, 600, "/", "", false, true) // 10 minutes
 
-	url := services.OAuth.LinkedIn.AuthCodeURL(state)
+	url := provider.AuthCodeURL(flow.State, flow.AuthCodeOptions()...)
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
-// LinkedInCallback godoc
-// @Summary      LinkedIn OAuth callback
-// @Description  Handles the callback from LinkedIn OAuth
+// UnlinkOAuthProvider godoc
+// @Summary      Unlink an OAuth provider
+// @Description  Removes a linked provider unless it is the user's only way to sign in
 // @Tags         OAuth
-// @Param        code  query string true "Authorization code"
-// @Param        state query string true "State token"
+// @Produce      json
+// @Param        provider path string true "Provider name"
 // @Success      200 {object} dto.SuccessResponse
-// @Failure      400 {object} dto.ErrorResponse
 // @Failure      401 {object} dto.ErrorResponse
-// @Failure      500 {object} dto.ErrorResponse
-// @Router       /auth/linkedin/callback [get]
-func LinkedInCallback(c *gin.Context) {
-	// Verify state
-	state := c.Query("state")
-	storedState, err := c.Cookie("oauth_state")
-	if err != nil || state != storedState {
-		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
-			Error: "Invalid state token",
-		})
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Router       /users/me/oauth/{provider} [delete]
+func UnlinkOAuthProvider(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
 		return
 	}
 
-	c.SetCookie("oauth_state", "", -1, "/", "", false, true)
+	provider := models.OAuthProvider(c.Param("provider"))
 
-	code := c.Query("code")
-	token, err := services.OAuth.LinkedIn.Exchange(c.Request.Context(), code)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
-			Error: "Failed to exchange token",
+	var account models.OAuthAccount
+	if err := config.DB.Where("user_id = ? AND provider = ?", user.ID, provider).
+		First(&account).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error: "Provider not linked",
 		})
 		return
 	}
 
-	userInfo, err := services.GetLinkedInUserInfo(c.Request.Context(), token)
-	if err != nil {
+	// Magic links are not counted: they only prove mailbox access, which is
+	// what an attacker who took over the inbox would have
+	var otherProviders, passkeys int64
+	config.DB.Model(&models.OAuthAccount{}).
+		Where("user_id = ? AND id <> ?", user.ID, account.ID).Count(&otherProviders)
+	config.DB.Model(&models.WebAuthnCredential{}).
+		Where("user_id = ?", user.ID).Count(&passkeys)
+
+	if user.Password == nil && otherProviders == 0 && passkeys == 0 {
+		c.JSON(http.StatusConflict, dto.ErrorResponse{
+			Error: "Cannot remove your only sign-in method; set a password or add a passkey first",
+		})
+		return
+	}
+
+	// Hard delete so the same provider account can be linked again later
+	if err := config.DB.Unscoped().Delete(&account).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Error: "Failed to get user info",
+			Error: "Failed to unlink provider",
 		})
 		return
 	}
 
-	handleOAuthLogin(c, userInfo, token, models.OAuthLinkedIn)
+	services.RecordAudit(c, models.AuditOAuthUnlinked, user.ID, map[string]any{
+		"provider":     string(provider),
+		"provider_uid": account.ProviderUID,
+	})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Provider unlinked",
+	})
+}
+
+// lookupProvider resolves the :provider path param, writing a 404 if it is not registered
+func lookupProvider(c *gin.Context) (services.Provider, bool) {
+	provider, ok := services.GetProvider(models.OAuthProvider(c.Param("provider")))
+	if !ok {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error: "Unknown OAuth provider",
+		})
+		return nil, false
+	}
+	return provider, true
 }
 
 // handleOAuthLogin handles the common OAuth login logic
-func handleOAuthLogin(c *gin.Context, userInfo *services.OAuthUserInfo, token *oauth2.Token, provider models.OAuthProvider) {
+func handleOAuthLogin(c *gin.Context, userInfo *services.OAuthUserInfo, token *oauth2.Token, provider models.OAuthProvider, returnTo string) {
 	var user models.User
 	var oauthAccount models.OAuthAccount
 
@@ -226,91 +246,173 @@ func handleOAuthLogin(c *gin.Context, userInfo *services.OAuthUserInfo, token *o
 			return
 		}
 
-		// Update OAuth account tokens
-		oauthAccount.AccessToken = token.AccessToken
-		oauthAccount.RefreshToken = token.RefreshToken
-		if !token.Expiry.IsZero() {
-			oauthAccount.ExpiresAt = &token.Expiry
-		}
-		oauthAccount.AvatarURL = userInfo.AvatarURL
+		updateOAuthAccountTokens(&oauthAccount, userInfo, token)
 		config.DB.Save(&oauthAccount)
 	} else {
 		// Check if user exists by email
 		err = config.DB.Where("email = ?", userInfo.Email).First(&user).Error
 
-		if err != nil {
+		if err == nil {
+			// Matching by email is only safe when the provider vouches for the
+			// address; otherwise anyone could claim it and take over the account
+			if !userInfo.EmailVerified {
+				services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{
+					"method": string(provider),
+					"reason": "unverified_provider_email",
+				})
+				c.JSON(http.StatusConflict, dto.ErrorResponse{
+					Error: "An account with this email already exists. Sign in and link " + string(provider) + " from your profile",
+				})
+				return
+			}
+
+			// Someone may have signed up with this address without owning it.
+			// The provider just proved ownership, so take away every way they
+			// could still sign in.
+			if !user.EmailVerified {
+				claimed, err := services.ClaimUnverifiedAccount(user.ID)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+						Error: "Failed to update user",
+					})
+					return
+				}
+				user.EmailVerified = true
+				if claimed {
+					user.Password = nil
+					services.RecordAudit(c, models.AuditSessionsRevoked, user.ID, map[string]any{
+						"reason": "unverified_account_claimed",
+					})
+				}
+			}
+		} else {
 			// Create new user
 			user = models.User{
 				FirstName:     userInfo.FirstName,
 				LastName:      stringPtr(userInfo.LastName),
 				Email:         userInfo.Email,
-				EmailVerified: true, // OAuth emails are typically verified
+				EmailVerified: userInfo.EmailVerified,
 				PhoneVerified: false,
 			}
 
 			if err := config.DB.Create(&user).Error; err != nil {
+				// The address is still held by a deleted account
+				if purgeAt, pending := services.PendingDeletion(userInfo.Email); pending {
+					accountPendingDeletion(c, purgeAt)
+					return
+				}
 				c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 					Error: "Failed to create user",
 				})
 				return
 			}
+			services.RecordAudit(c, models.AuditSignup, user.ID, map[string]any{"method": string(provider)})
 		}
 
-		// Create OAuth account
-		expiresAt := token.Expiry
-		oauthAccount = models.OAuthAccount{
-			UserID:       user.ID,
-			Provider:     provider,
-			ProviderUID:  userInfo.ID,
-			Email:        userInfo.Email,
-			AvatarURL:    userInfo.AvatarURL,
-			AccessToken:  token.AccessToken,
-			RefreshToken: token.RefreshToken,
+		if err := createOAuthAccount(user.ID, userInfo, token, provider); err != nil {
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+				Error: "Failed to link OAuth account",
+			})
+			return
 		}
-		if !expiresAt.IsZero() {
-			oauthAccount.ExpiresAt = &expiresAt
+		services.RecordAudit(c, models.AuditOAuthLinked, user.ID, map[string]any{
+			"provider":     string(provider),
+			"provider_uid": userInfo.ID,
+			"via":          "login",
+		})
+	}
+
+	completeRedirectLogin(c, user, returnTo, string(provider))
+}
+
+// handleOAuthLink attaches the provider account to the user who started the
+// link flow, refusing accounts that already belong to someone else
+func handleOAuthLink(c *gin.Context, userID uuid.UUID, userInfo *services.OAuthUserInfo, token *oauth2.Token, provider models.OAuthProvider, returnTo string) {
+	var existing models.OAuthAccount
+	err := config.DB.Where("provider = ? AND provider_uid = ?", provider, userInfo.ID).
+		First(&existing).Error
+
+	switch {
+	case err == nil && existing.UserID != userID:
+		c.JSON(http.StatusConflict, dto.ErrorResponse{
+			Error: "This " + string(provider) + " account is linked to another user",
+		})
+		return
+	case err == nil:
+		// Relinking the same account refreshes its tokens
+		updateOAuthAccountTokens(&existing, userInfo, token)
+		config.DB.Save(&existing)
+	default:
+		var count int64
+		config.DB.Model(&models.OAuthAccount{}).
+			Where("user_id = ? AND provider = ?", userID, provider).Count(&count)
+		if count > 0 {
+			c.JSON(http.StatusConflict, dto.ErrorResponse{
+				Error: "A different " + string(provider) + " account is already linked; unlink it first",
+			})
+			return
 		}
 
-		if err := config.DB.Create(&oauthAccount).Error; err != nil {
+		if err := createOAuthAccount(userID, userInfo, token, provider); err != nil {
 			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
 				Error: "Failed to link OAuth account",
 			})
 			return
 		}
+		services.RecordAudit(c, models.AuditOAuthLinked, userID, map[string]any{
+			"provider":     string(provider),
+			"provider_uid": userInfo.ID,
+			"via":          "link",
+		})
 	}
 
-	// Create session
-	session := models.Session{
-		UserID:    user.ID,
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
-	}
-
-	if err := config.DB.Create(&session).Error; err != nil {
+	// Adding a sign-in method raises the session's privilege, so rotate it
+	if _, err := services.IssueSession(c, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
-			Error: "Failed to create session",
+			Error: "Failed to create a session",
 		})
 		return
 	}
 
-	// Set session cookie
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie(
-		"session_id",
-		session.ID.String(),
-		60*60*24*7, // 7 days
-		"/",
-		"",
-		false, // true in production (HTTPS)
-		true,  // HttpOnly
-	)
-
-	// Redirect to frontend callback page
-	frontendURL := os.Getenv("FRONTEND_URL")
-	if frontendURL == "" {
-		frontendURL = "http://localhost:5173"
+	if returnTo == "" {
+		returnTo = "/profile"
+	}
+	c.Redirect(http.StatusTemporaryRedirect, services.FrontendURL()+returnTo)
+}
+
+func createOAuthAccount(userID uuid.UUID, userInfo *services.OAuthUserInfo, token *oauth2.Token, provider models.OAuthProvider) error {
+	now := time.Now()
+	oauthAccount := models.OAuthAccount{
+		UserID:       userID,
+		Provider:     provider,
+		ProviderUID:  userInfo.ID,
+		Email:        userInfo.Email,
+		AvatarURL:    userInfo.AvatarURL,
+		AccessToken:  token.AccessToken,
+		RefreshToken: token.RefreshToken,
+		LastUsedAt:   &now,
 	}
+	if !token.Expiry.IsZero() {
+		expiresAt := token.Expiry
+		oauthAccount.ExpiresAt = &expiresAt
+	}
+	return config.DB.Create(&oauthAccount).Error
+}
 
-	c.Redirect(http.StatusTemporaryRedirect, frontendURL+"/callback")
+func updateOAuthAccountTokens(oauthAccount *models.OAuthAccount, userInfo *services.OAuthUserInfo, token *oauth2.Token) {
+	oauthAccount.AccessToken = token.AccessToken
+	// Providers often only send a refresh token on first consent
+	if token.RefreshToken != "" {
+		oauthAccount.RefreshToken = token.RefreshToken
+	}
+	if !token.Expiry.IsZero() {
+		expiresAt := token.Expiry
+		oauthAccount.ExpiresAt = &expiresAt
+	}
+	oauthAccount.ReauthRequired = false
+	oauthAccount.AvatarURL = userInfo.AvatarURL
+	now := time.Now()
+	oauthAccount.LastUsedAt = &now
 }
 
 // stringPtr returns a pointer to the string
@@ -320,3 +422,45 @@ func stringPtr(s string) *string {
 	}
 	return &s
 }
+
+// completeRedirectLogin finishes an OAuth login, which arrives as a browser
+// redirect: users with a second factor are sent to the frontend MFA step,
+// everyone else gets a session and lands on returnTo, or the frontend callback
+// page.
+// method names the first factor for the audit log.
+func completeRedirectLogin(c *gin.Context, user models.User, returnTo, method string) {
+	if user.SuspendedAt != nil {
+		services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{"method": method, "reason": "suspended"})
+		accountSuspended(c)
+		return
+	}
+
+	challenge, err := beginMFAChallenge(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to start two-factor login",
+		})
+		return
+	}
+	if challenge != nil {
+		target := services.FrontendURL() + "/login/mfa?challenge=" + challenge.ID.String()
+		if returnTo != "" {
+			target += "&return_to=" + url.QueryEscape(returnTo)
+		}
+		c.Redirect(http.StatusTemporaryRedirect, target)
+		return
+	}
+
+	if _, err := services.IssueSession(c, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to create session",
+		})
+		return
+	}
+	services.RecordAudit(c, models.AuditLogin, user.ID, map[string]any{"method": method})
+
+	if returnTo == "" {
+		returnTo = "/callback"
+	}
+	c.Redirect(http.StatusTemporaryRedirect, services.FrontendURL()+returnTo)
+}
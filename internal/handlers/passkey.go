@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const ceremonyCookieName = "webauthn_ceremony"
+
+// BeginPasskeyRegistration godoc
+// @Summary      Start passkey registration
+// @Description  Returns PublicKeyCredentialCreationOptions to pass to navigator.credentials.create()
+// @Tags         Passkeys
+// @Produce      json
+// @Success      200 {object} object
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/passkeys/register/begin [post]
+func BeginPasskeyRegistration(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	creation, ceremonyID, err := services.BeginPasskeyRegistration(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to start passkey registration",
+		})
+		return
+	}
+
+	setCeremonyCookie(c, ceremonyID)
+	c.JSON(http.StatusOK, creation)
+}
+
+// FinishPasskeyRegistration godoc
+// @Summary      Finish passkey registration
+// @Description  Verifies the authenticator response from navigator.credentials.create() and stores the passkey
+// @Tags         Passkeys
+// @Accept       json
+// @Produce      json
+// @Param        name query string false "Label for the passkey"
+// @Success      201 {object} dto.PasskeyResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Router       /users/me/passkeys/register/finish [post]
+func FinishPasskeyRegistration(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	ceremonyID, ok := takeCeremonyCookie(c)
+	if !ok {
+		return
+	}
+
+	credential, err := services.FinishPasskeyRegistration(user, ceremonyID, c.Query("name"), c.Request)
+	if err != nil {
+		if !errors.Is(err, services.ErrCeremonyNotFound) {
+			log.Printf("passkey registration failed: %v", err)
+		}
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Passkey registration failed",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.ToPasskeyResponse(*credential))
+}
+
+// ListPasskeys godoc
+// @Summary      List passkeys
+// @Description  Returns the passkeys registered by the logged-in user
+// @Tags         Passkeys
+// @Produce      json
+// @Success      200 {array}  dto.PasskeyResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/passkeys [get]
+func ListPasskeys(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var credentials []models.WebAuthnCredential
+	if err := config.DB.
+		Where("user_id = ?", user.ID).
+		Order("created_at").
+		Find(&credentials).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to load passkeys",
+		})
+		return
+	}
+
+	response := make([]dto.PasskeyResponse, 0, len(credentials))
+	for _, credential := range credentials {
+		response = append(response, dto.ToPasskeyResponse(credential))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// DeletePasskey godoc
+// @Summary      Delete a passkey
+// @Description  Removes one of the logged-in user's passkeys
+// @Tags         Passkeys
+// @Produce      json
+// @Param        id path string true "Passkey ID"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /users/me/passkeys/{id} [delete]
+func DeletePasskey(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid passkey id",
+		})
+		return
+	}
+
+	result := config.DB.Delete(&models.WebAuthnCredential{}, "id = ? AND user_id = ?", id, user.ID)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to delete passkey",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error: "Passkey not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Passkey deleted",
+	})
+}
+
+// BeginPasskeyLogin godoc
+// @Summary      Start passkey login
+// @Description  Returns PublicKeyCredentialRequestOptions to pass to navigator.credentials.get()
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {object} object
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/passkey/begin [post]
+func BeginPasskeyLogin(c *gin.Context) {
+	assertion, ceremonyID, err := services.BeginPasskeyLogin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to start passkey login",
+		})
+		return
+	}
+
+	setCeremonyCookie(c, ceremonyID)
+	c.JSON(http.StatusOK, assertion)
+}
+
+// FinishPasskeyLogin godoc
+// @Summary      Finish passkey login
+// @Description  Verifies the authenticator response from navigator.credentials.get() and starts a session
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/passkey/finish [post]
+func FinishPasskeyLogin(c *gin.Context) {
+	ceremonyID, ok := takeCeremonyCookie(c)
+	if !ok {
+		return
+	}
+
+	user, err := services.FinishPasskeyLogin(ceremonyID, c.Request)
+	if err != nil {
+		if !errors.Is(err, services.ErrCeremonyNotFound) && !errors.Is(err, services.ErrPasskeyCloned) {
+			log.Printf("passkey login failed: %v", err)
+		}
+		services.RecordAudit(c, models.AuditLoginFailed, uuid.Nil, map[string]any{"method": "passkey"})
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "Passkey login failed",
+		})
+		return
+	}
+
+	// A user-verified passkey already combines possession and a local
+	// PIN/biometric, so no further MFA challenge is needed
+	if user.SuspendedAt != nil {
+		services.RecordAudit(c, models.AuditLoginFailed, user.ID, map[string]any{"method": "passkey", "reason": "suspended"})
+		accountSuspended(c)
+		return
+	}
+
+	if _, err := services.IssueSession(c, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to create a session",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditLogin, user.ID, map[string]any{"method": "passkey"})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Logged In",
+	})
+}
+
+func setCeremonyCookie(c *gin.Context, id uuid.UUID) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(ceremonyCookieName, id.String(), 300, "/", "", false, true) // 5 minutes
+}
+
+// takeCeremonyCookie reads and clears the ceremony cookie, writing an error response if missing
+func takeCeremonyCookie(c *gin.Context) (uuid.UUID, bool) {
+	value, err := c.Cookie(ceremonyCookieName)
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(ceremonyCookieName, "", -1, "/", "", false, true)
+
+	id, parseErr := uuid.Parse(value)
+	if err != nil || parseErr != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Passkey ceremony expired, please try again",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const passwordResetTTL = time.Hour
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword" binding:"required"`
+}
+
+// ForgotPassword godoc
+// @Summary      Request a password reset link
+// @Description  Emails a single-use reset link if the address belongs to an account. The response is identical either way
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body ForgotPasswordRequest true "Forgot Password Request"
+// @Success      202 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Router       /auth/password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	// Look the user up and send the mail off the request path so the response
+	// time does not reveal whether the email is registered.
+	go sendPasswordReset(req.Email)
+
+	c.JSON(http.StatusAccepted, dto.SuccessResponse{
+		Success: "If an account exists for that email, a reset link has been sent",
+	})
+}
+
+// ResetPassword godoc
+// @Summary      Reset password
+// @Description  Sets a new password using an emailed reset token and signs out every session
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body ResetPasswordRequest true "Reset Password Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.PasswordPolicyErrorResponse "Invalid input, or violations of the password policy"
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	// Check the new password before spending the token, so a rejected
+	// password can be corrected without requesting another link
+	pending, err := services.PeekUserToken(req.Token, models.TokenPasswordReset)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid or expired reset token",
+		})
+		return
+	}
+	var owner models.User
+	if err := config.DB.First(&owner, "id = ?", pending.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid or expired reset token",
+		})
+		return
+	}
+	if !checkPasswordPolicy(c, req.Password, owner) {
+		return
+	}
+
+	hashed, err := services.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to hash password",
+		})
+		return
+	}
+
+	// The token is only spent if the new password is stored with it
+	var token *models.UserToken
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = services.ConsumeUserTokenTx(tx, req.Token, models.TokenPasswordReset)
+		if err != nil {
+			return err
+		}
+
+		// Following the emailed link also proves ownership of the address
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", token.UserID).
+			Updates(map[string]any{
+				"password":           hashed,
+				"email_verified":     true,
+				"failed_login_count": 0,
+				"locked_until":       nil,
+			}).Error; err != nil {
+			return err
+		}
+		return services.RevokeSessions(tx, token.UserID, uuid.Nil)
+	})
+	if errors.Is(err, services.ErrInvalidToken) {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid or expired reset token",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to reset password",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditPasswordReset, token.UserID, nil)
+	services.ClearSessionCookie(c)
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Password has been reset",
+	})
+}
+
+// ChangePassword godoc
+// @Summary      Change or set password
+// @Description  Changes the password of the logged-in user. Accounts created through OAuth may set a first password without supplying a current one. Every other session is signed out and the current session is rotated
+// @Tags         Users
+// @Accept       json
+// @Produce      json
+// @Param        request body ChangePasswordRequest true "Change Password Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.PasswordPolicyErrorResponse "Invalid input, or violations of the password policy"
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/password [put]
+func ChangePassword(c *gin.Context) {
+	var req ChangePasswordRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+	session, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	if user.Password != nil {
+		if req.CurrentPassword == "" {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+				Error: "Current password is required",
+			})
+			return
+		}
+		if !services.VerifyPassword(*user.Password, req.CurrentPassword) {
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+				Error: "Current password is incorrect",
+			})
+			return
+		}
+	}
+
+	if !checkPasswordPolicy(c, req.NewPassword, user) {
+		return
+	}
+	if user.Password != nil && services.VerifyPassword(*user.Password, req.NewPassword) {
+		c.JSON(http.StatusBadRequest, dto.PasswordPolicyErrorResponse{
+			Error: "Password does not meet the requirements",
+			Violations: []dto.PasswordViolation{{
+				Rule:    services.PasswordRuleReused,
+				Message: "New password must be different from the current one",
+			}},
+		})
+		return
+	}
+
+	hashed, err := services.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to hash password",
+		})
+		return
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).
+			Where("id = ?", user.ID).
+			Update("password", hashed).Error; err != nil {
+			return err
+		}
+		return services.RevokeSessions(tx, user.ID, session.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to change password",
+		})
+		return
+	}
+
+	// Rotate the current session so its old ID stops working too
+	if _, err := services.IssueSession(c, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to create a session",
+		})
+		return
+	}
+
+	message := "Password changed"
+	if user.Password == nil {
+		message = "Password set"
+	}
+	services.RecordAudit(c, models.AuditPasswordChanged, user.ID, map[string]any{"initial": user.Password == nil})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: message,
+	})
+}
+
+// checkPasswordPolicy validates a new password for user, writing a 400 that
+// lists each broken rule when it is rejected
+func checkPasswordPolicy(c *gin.Context, password string, user models.User) bool {
+	subject := services.PasswordSubject{Email: user.Email, FirstName: user.FirstName}
+	if user.LastName != nil {
+		subject.LastName = *user.LastName
+	}
+
+	violations := services.ValidatePassword(password, subject)
+	if len(violations) == 0 {
+		return true
+	}
+
+	response := dto.PasswordPolicyErrorResponse{
+		Error:      "Password does not meet the requirements",
+		Violations: make([]dto.PasswordViolation, 0, len(violations)),
+	}
+	for _, v := range violations {
+		response.Violations = append(response.Violations, dto.PasswordViolation{Rule: v.Rule, Message: v.Message})
+	}
+	c.JSON(http.StatusBadRequest, response)
+	return false
+}
+
+// sendPasswordReset mails a reset link to the account with the given email, if any
+func sendPasswordReset(email string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := config.DB.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+		return
+	}
+
+	token, err := services.IssueUserToken(user.ID, models.TokenPasswordReset, passwordResetTTL)
+	if err != nil {
+		log.Printf("failed to issue password reset token: %v", err)
+		return
+	}
+
+	if err := services.SendPasswordResetEmail(ctx, user, token); err != nil {
+		log.Printf("failed to send password reset email: %v", err)
+	}
+}
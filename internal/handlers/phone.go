@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// phoneOTPLimiter caps texts per user, on top of the resend cooldown, to
+// limit SMS cost if an account is abused
+var phoneOTPLimiter = services.NewRateLimiter("phone-otp-user", 5, time.Hour)
+
+// phoneVerifyLimiter caps code guesses per user across every code they are
+// sent, as each new code resets the per-code attempt count
+var phoneVerifyLimiter = services.NewRateLimiter("phone-verify-user", 10, time.Hour)
+
+type SetPhoneRequest struct {
+	Phone string `json:"phone" binding:"required,max=32"`
+}
+
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// SetPhone godoc
+// @Summary      Set phone number
+// @Description  Saves the logged-in user's phone number in E.164 form. The number must then be verified with a code
+// @Tags         Phone
+// @Accept       json
+// @Produce      json
+// @Param        request body SetPhoneRequest true "Set Phone Request"
+// @Success      200 {object} dto.PhoneResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/phone [post]
+func SetPhone(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req SetPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	phone, err := services.NormalizePhone(req.Phone)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid phone number, use international format such as +14155550100",
+		})
+		return
+	}
+
+	// Re-submitting the current number keeps its verification
+	if user.Phone != nil && *user.Phone == phone {
+		c.JSON(http.StatusOK, dto.PhoneResponse{Phone: phone, PhoneVerified: user.PhoneVerified})
+		return
+	}
+
+	if err := services.SetPhone(user.ID, phone); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to update phone number",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditPhoneChanged, user.ID, nil)
+	c.JSON(http.StatusOK, dto.PhoneResponse{Phone: phone, PhoneVerified: false})
+}
+
+// SendPhoneOTP godoc
+// @Summary      Send phone verification code
+// @Description  Texts a six digit code to the logged-in user's phone number. Codes expire after 10 minutes
+// @Tags         Phone
+// @Produce      json
+// @Success      202 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Failure      429 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/phone/otp [post]
+func SendPhoneOTP(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if allowed, retryAfter := phoneOTPLimiter.Allow(user.ID.String()); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+
+	if err := services.SendPhoneOTP(c.Request.Context(), user, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoPhone):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Add a phone number first"})
+		case errors.Is(err, services.ErrPhoneAlreadyVerified):
+			c.JSON(http.StatusConflict, dto.ErrorResponse{Error: "Phone number is already verified"})
+		case errors.Is(err, services.ErrPhoneOTPTooSoon):
+			tooManyRequests(c, time.Minute)
+		default:
+			log.Printf("failed to send phone verification code: %v", err)
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to send verification code"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, dto.SuccessResponse{
+		Success: "Verification code sent",
+	})
+}
+
+// VerifyPhone godoc
+// @Summary      Verify phone number
+// @Description  Confirms the code texted to the logged-in user's phone. Five wrong codes discard it
+// @Tags         Phone
+// @Accept       json
+// @Produce      json
+// @Param        request body VerifyPhoneRequest true "Verify Phone Request"
+// @Success      200 {object} dto.PhoneResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      429 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/phone/verify [post]
+func VerifyPhone(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var req VerifyPhoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	if allowed, retryAfter := phoneVerifyLimiter.Allow(user.ID.String()); !allowed {
+		tooManyRequests(c, retryAfter)
+		return
+	}
+
+	if err := services.VerifyPhoneOTP(user.ID, req.Code, time.Now()); err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPhoneOTP):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Invalid verification code"})
+		case errors.Is(err, services.ErrPhoneOTPExpired):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "Verification code expired, request a new one"})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to verify phone number"})
+		}
+		return
+	}
+
+	services.RecordAudit(c, models.AuditPhoneVerified, user.ID, nil)
+	c.JSON(http.StatusOK, dto.PhoneResponse{Phone: *user.Phone, PhoneVerified: true})
+}
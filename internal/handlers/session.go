@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListSessions godoc
+// @Summary      List active sessions
+// @Description  Returns every unexpired session of the logged-in user, most recently used first
+// @Tags         Sessions
+// @Produce      json
+// @Success      200 {array}  dto.SessionResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/sessions [get]
+func ListSessions(c *gin.Context) {
+	current, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	var sessions []models.Session
+	if err := config.DB.
+		Where("user_id = ? AND expires_at > ?", current.UserID, time.Now()).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to load sessions",
+		})
+		return
+	}
+
+	response := make([]dto.SessionResponse, 0, len(sessions))
+	for _, s := range sessions {
+		response = append(response, dto.ToSessionResponse(s, current.ID))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetSession godoc
+// @Summary      Inspect a session
+// @Description  Returns a single session of the logged-in user
+// @Tags         Sessions
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} dto.SessionResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /users/me/sessions/{id} [get]
+func GetSession(c *gin.Context) {
+	current, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	session, ok := findUserSession(c, current.UserID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToSessionResponse(session, current.ID))
+}
+
+// RevokeSession godoc
+// @Summary      Revoke a session
+// @Description  Signs the given session out. Revoking the current session also clears the cookie
+// @Tags         Sessions
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/sessions/{id} [delete]
+func RevokeSession(c *gin.Context) {
+	current, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	session, ok := findUserSession(c, current.UserID)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Delete(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to revoke session",
+		})
+		return
+	}
+
+	if session.ID == current.ID {
+		services.ClearSessionCookie(c)
+	}
+	services.RecordAudit(c, models.AuditSessionRevoked, current.UserID, map[string]any{"session_id": session.ID})
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Session revoked",
+	})
+}
+
+// RevokeOtherSessions godoc
+// @Summary      Sign out everywhere else
+// @Description  Revokes every session of the logged-in user except the current one
+// @Tags         Sessions
+// @Produce      json
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /users/me/sessions [delete]
+func RevokeOtherSessions(c *gin.Context) {
+	current, ok := currentSession(c)
+	if !ok {
+		return
+	}
+
+	if err := services.RevokeSessions(config.DB, current.UserID, current.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to revoke sessions",
+		})
+		return
+	}
+
+	services.RecordAudit(c, models.AuditSessionsRevoked, current.UserID, map[string]any{"kept_session_id": current.ID})
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Signed out of all other sessions",
+	})
+}
+
+// findUserSession loads the session named by the :id path param, scoped to the given user
+func findUserSession(c *gin.Context, userID uuid.UUID) (models.Session, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: "Invalid session id",
+		})
+		return models.Session{}, false
+	}
+
+	var session models.Session
+	if err := config.DB.First(&session, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{
+			Error: "Session not found",
+		})
+		return models.Session{}, false
+	}
+
+	return session, true
+}
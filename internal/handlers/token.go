@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	GrantPassword     = "password"
+	GrantMFA          = "mfa"
+	GrantRefreshToken = "refresh_token"
+)
+
+type TokenRequest struct {
+	GrantType    string `json:"grantType" binding:"required,oneof=password mfa refresh_token"`
+	Email        string `json:"email" binding:"required_if=GrantType password,omitempty,email"`
+	Password     string `json:"password" binding:"required_if=GrantType password"`
+	ChallengeID  string `json:"challengeId" binding:"required_if=GrantType mfa,omitempty,uuid"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+	RefreshToken string `json:"refreshToken" binding:"required_if=GrantType refresh_token"`
+}
+
+type RevokeTokenRequest struct {
+	RefreshToken string `json:"refreshToken" binding:"required"`
+}
+
+// IssueToken godoc
+// @Summary      Get access and refresh tokens
+// @Description  Token endpoint for native clients that cannot use the session cookie. The "password" grant takes an email and password and, for users with a second factor, answers with an MFA challenge to complete with the "mfa" grant. The "refresh_token" grant exchanges a refresh token for a new pair; each refresh token works once, and presenting a used one again revokes every token descended from the same login
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body TokenRequest true "Token Request"
+// @Success      200 {object} dto.TokenResponse "Tokens, or dto.MFAChallengeResponse when a second factor is required"
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse "Account suspended"
+// @Failure      429 {object} dto.ErrorResponse "Rate limited, see Retry-After"
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/token [post]
+func IssueToken(c *gin.Context) {
+	var req TokenRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	switch req.GrantType {
+	case GrantPassword:
+		passwordGrant(c, req)
+	case GrantMFA:
+		mfaGrant(c, req)
+	case GrantRefreshToken:
+		refreshTokenGrant(c, req)
+	}
+}
+
+// RevokeToken godoc
+// @Summary      Revoke a refresh token
+// @Description  Signs a native client out by revoking its refresh token and every token rotated from the same login. Access tokens already issued stay valid until they expire
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param        request body RevokeTokenRequest true "Revoke Token Request"
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/token/revoke [post]
+func RevokeToken(c *gin.Context) {
+	var req RevokeTokenRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{
+			Error: err.Error(),
+		})
+		return
+	}
+
+	token, err := services.RevokeRefreshToken(req.RefreshToken)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to revoke token",
+		})
+		return
+	}
+	if token != nil {
+		services.RecordAudit(c, models.AuditLogout, token.UserID, map[string]any{"method": "token"})
+	}
+
+	// Unknown tokens get the same answer so the endpoint cannot be used to probe them
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Token revoked",
+	})
+}
+
+// JWKS godoc
+// @Summary      Access token signing keys
+// @Description  Public keys, as a JSON Web Key Set, that verify access tokens issued by /auth/token
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {object} services.JSONWebKeySet
+// @Router       /.well-known/jwks.json [get]
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, services.PublicSigningKeys())
+}
+
+func passwordGrant(c *gin.Context, req TokenRequest) {
+	user, ok := authenticatePassword(c, req.Email, req.Password)
+	if !ok {
+		return
+	}
+
+	challenge, err := beginMFAChallenge(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to start two-factor login",
+		})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, dto.MFAChallengeResponse{
+			MFARequired: true,
+			ChallengeID: challenge.ID,
+			ExpiresAt:   challenge.ExpiresAt,
+		})
+		return
+	}
+
+	if issueTokenPair(c, user.ID) {
+		services.RecordAudit(c, models.AuditLogin, user.ID, map[string]any{"method": "password", "client": "token"})
+	}
+}
+
+func mfaGrant(c *gin.Context, req TokenRequest) {
+	userID, err := services.CompleteMFAChallenge(uuid.MustParse(req.ChallengeID), req.Code, req.RecoveryCode, time.Now())
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidMFACode) {
+			services.RecordAudit(c, models.AuditLoginFailed, userID, map[string]any{"reason": "bad_mfa_code"})
+		}
+		switch {
+		case errors.Is(err, services.ErrInvalidMFACode):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Invalid verification code"})
+		case errors.Is(err, services.ErrMFAChallengeExpired):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Login attempt expired, please sign in again"})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to verify code"})
+		}
+		return
+	}
+
+	secondFactor := "totp"
+	if req.RecoveryCode != "" {
+		secondFactor = "recovery_code"
+	}
+	if issueTokenPair(c, userID) {
+		services.RecordAudit(c, models.AuditLogin, userID, map[string]any{"method": "mfa", "second_factor": secondFactor, "client": "token"})
+	}
+}
+
+func refreshTokenGrant(c *gin.Context, req TokenRequest) {
+	raw, refresh, err := services.RotateRefreshToken(c, req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRefreshTokenReused):
+			services.RecordAudit(c, models.AuditRefreshTokenReused, refresh.UserID, map[string]any{"family_id": refresh.FamilyID})
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Refresh token already used, please sign in again"})
+		case errors.Is(err, services.ErrInvalidRefreshToken):
+			c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Invalid or expired refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "Failed to refresh token"})
+		}
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, "id = ?", refresh.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{Error: "Invalid or expired refresh token"})
+		return
+	}
+	if user.SuspendedAt != nil {
+		services.RevokeRefreshTokens(config.DB, user.ID)
+		accountSuspended(c)
+		return
+	}
+
+	writeTokenPair(c, raw, refresh)
+}
+
+// issueTokenPair starts a refresh token family for the user and writes the
+// token response, reporting whether it succeeded
+func issueTokenPair(c *gin.Context, userID uuid.UUID) bool {
+	raw, refresh, err := services.IssueRefreshToken(c, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to issue tokens",
+		})
+		return false
+	}
+	return writeTokenPair(c, raw, refresh)
+}
+
+func writeTokenPair(c *gin.Context, rawRefresh string, refresh *models.RefreshToken) bool {
+	now := time.Now()
+	access, expiresAt, err := services.SignAccessToken(refresh.UserID, refresh.FamilyID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to issue tokens",
+		})
+		return false
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, dto.TokenResponse{
+		AccessToken:      access,
+		TokenType:        "Bearer",
+		ExpiresIn:        int(expiresAt.Sub(now).Seconds()),
+		RefreshToken:     rawRefresh,
+		RefreshExpiresIn: int(refresh.ExpiresAt.Sub(now).Seconds()),
+	})
+	return true
+}
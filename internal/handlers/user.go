@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"build-in-public/internal/config"
 	"build-in-public/internal/dto"
 	"build-in-public/internal/models"
 	"net/http"
@@ -16,21 +17,55 @@ import (
 // @Failure      401 {object} dto.ErrorResponse
 // @Router       /users/me [get]
 func Me(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	// Tokens stay out of the query, only the provider list is returned
+	config.DB.Select("id", "provider", "reauth_required").
+		Where("user_id = ?", user.ID).
+		Find(&user.OAuthAccounts)
+
+	response := dto.ToUserResponse(user)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// currentUser returns the user attached by middleware.RequireAuth, writing an error response if missing
+func currentUser(c *gin.Context) (models.User, bool) {
 	userAny, exists := c.Get("user")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
 			Error: "unauthorized",
 		})
-		return
+		return models.User{}, false
 	}
 
 	user, ok := userAny.(models.User)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "invalid user type"})
-		return
+		return models.User{}, false
 	}
 
-	response := dto.ToUserResponse(user)
+	return user, true
+}
 
-	c.JSON(http.StatusOK, response)
+// currentSession returns the session attached by middleware.RequireAuth, writing an error response if missing
+func currentSession(c *gin.Context) (models.Session, bool) {
+	sessionAny, exists := c.Get("session")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, dto.ErrorResponse{
+			Error: "unauthorized",
+		})
+		return models.Session{}, false
+	}
+
+	session, ok := sessionAny.(models.Session)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: "invalid session type"})
+		return models.Session{}, false
+	}
+
+	return session, true
 }
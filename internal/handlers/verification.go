@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/dto"
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const emailVerificationTTL = 24 * time.Hour
+
+// RequestEmailVerification godoc
+// @Summary      Send email verification link
+// @Description  Emails the logged-in user a single-use link confirming their address
+// @Tags         Auth
+// @Produce      json
+// @Success      200 {object} dto.SuccessResponse
+// @Failure      401 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /auth/verify-email/request [post]
+func RequestEmailVerification(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.EmailVerified {
+		c.JSON(http.StatusConflict, dto.ErrorResponse{
+			Error: "Email already verified",
+		})
+		return
+	}
+
+	if err := sendVerificationEmail(c, user); err != nil {
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{
+			Error: "Failed to send verification email",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.SuccessResponse{
+		Success: "Verification email sent",
+	})
+}
+
+// ConfirmEmailVerification godoc
+// @Summary      Confirm email address
+// @Description  Consumes the emailed token, marks the email as verified and redirects to the frontend
+// @Tags         Auth
+// @Param        token query string true "Verification token"
+// @Success      307
+// @Router       /auth/verify-email/confirm [get]
+func ConfirmEmailVerification(c *gin.Context) {
+	redirect := services.FrontendURL() + "/verify-email?status="
+
+	// The token is only spent if the address is marked verified with it
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := services.ConsumeUserTokenTx(tx, c.Query("token"), models.TokenEmailVerification)
+		if err != nil {
+			return err
+		}
+		return tx.Model(&models.User{}).
+			Where("id = ?", token.UserID).
+			Update("email_verified", true).Error
+	})
+	if errors.Is(err, services.ErrInvalidToken) {
+		c.Redirect(http.StatusTemporaryRedirect, redirect+"invalid")
+		return
+	}
+	if err != nil {
+		log.Printf("failed to verify email: %v", err)
+		c.Redirect(http.StatusTemporaryRedirect, redirect+"error")
+		return
+	}
+
+	c.Redirect(http.StatusTemporaryRedirect, redirect+"success")
+}
+
+// sendVerificationEmail issues a fresh verification token and mails it to the user
+func sendVerificationEmail(c *gin.Context, user models.User) error {
+	token, err := services.IssueUserToken(user.ID, models.TokenEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	return services.SendVerificationEmail(c.Request.Context(), user, token)
+}
@@ -1,54 +1,247 @@
 package middleware
 
 import (
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"build-in-public/internal/config"
 	"build-in-public/internal/models"
+	"build-in-public/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
-func RequireAuth() gin.HandlerFunc {
+type authOptions struct {
+	requireVerifiedEmail bool
+	requireMFA           bool
+}
+
+// AuthOption tightens what RequireAuth accepts for a route group
+type AuthOption func(*authOptions)
+
+// WithVerifiedEmail rejects users who have not confirmed their email address
+func WithVerifiedEmail() AuthOption {
+	return func(o *authOptions) {
+		o.requireVerifiedEmail = true
+	}
+}
+
+// WithMFA rejects users who have not enrolled a second factor
+func WithMFA() AuthOption {
+	return func(o *authOptions) {
+		o.requireMFA = true
+	}
+}
+
+// RequireScope limits personal access tokens to those granted scope; browser
+// sessions and JWT access tokens act with the user's full rights and pass.
+// RequireAuth rejects personal access tokens on routes that declare no scope,
+// so put RequireScope before it to open a route to them. After RequireAuth it
+// narrows a group that already admits them, e.g. to a write scope.
+func RequireScope(scope models.Scope) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		sessionID, err := c.Cookie("session_id")
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
-			return
+		if value, ok := c.Get("access_token"); ok {
+			token := value.(models.PersonalAccessToken)
+			if !token.HasScope(scope) {
+				insufficientScope(c, scope)
+				return
+			}
+		} else {
+			scopes, _ := c.Get(requiredScopesKey)
+			c.Set(requiredScopesKey, append(asScopes(scopes), scope))
 		}
+		c.Next()
+	}
+}
 
-		id, err := uuid.Parse(sessionID)
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
-			return
+// requiredScopesKey holds the scopes declared by RequireScope ahead of RequireAuth
+const requiredScopesKey = "required_scopes"
+
+func asScopes(value any) []models.Scope {
+	scopes, _ := value.([]models.Scope)
+	return scopes
+}
+
+func insufficientScope(c *gin.Context, scope models.Scope) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient scope", "required_scope": scope})
+}
+
+func RequireAuth(opts ...AuthOption) gin.HandlerFunc {
+	var options authOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return func(c *gin.Context) {
+		var user models.User
+		var session *models.Session
+		var accessToken *models.PersonalAccessToken
+
+		if bearer, ok := bearerToken(c); ok && !services.IsPersonalAccessToken(bearer) {
+			claims, err := services.AuthenticateAccessToken(bearer, time.Now())
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
+				return
+			}
+			userID, err := claims.UserID()
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
+				return
+			}
+			if err := loadUser(&user, userID); err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+				return
+			}
+			c.Set("token_claims", *claims)
+		} else if ok {
+			token, err := services.AuthenticatePersonalAccessToken(bearer, c.ClientIP())
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid access token"})
+				return
+			}
+			scopes, _ := c.Get(requiredScopesKey)
+			required := asScopes(scopes)
+			if len(required) == 0 {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "personal access tokens are not accepted here"})
+				return
+			}
+			for _, scope := range required {
+				if !token.HasScope(scope) {
+					insufficientScope(c, scope)
+					return
+				}
+			}
+			accessToken = token
+
+			if err := loadUser(&user, token.UserID); err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+				return
+			}
+		} else {
+			sessionID, err := c.Cookie(services.SessionCookieName)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+				return
+			}
+
+			id, err := uuid.Parse(sessionID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid session"})
+				return
+			}
+
+			session = &models.Session{}
+			if err := config.DB.First(session, "id = ?", id).Error; err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+				return
+			}
+
+			if session.ImpersonatorID != nil {
+				if reason := services.ImpersonationEndReason(session, time.Now()); reason != "" {
+					if err := services.EndImpersonation(c, session, reason); err != nil {
+						log.Printf("failed to end impersonation: %v", err)
+					}
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "impersonation ended"})
+					return
+				}
+			}
+
+			if services.SessionExpired(session, time.Now()) {
+				config.DB.Delete(session)
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
+				return
+			}
+
+			if err := loadUser(&user, session.UserID); err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+				return
+			}
 		}
 
-		var session models.Session
-		if err := config.DB.First(&session, "id = ?", id).Error; err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session not found"})
+		if user.SuspendedAt != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account suspended"})
 			return
 		}
 
-		if session.ExpiresAt.Before(time.Now()) {
-			config.DB.Delete(&session)
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session expired"})
+		if options.requireVerifiedEmail && !user.EmailVerified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "email not verified"})
 			return
 		}
 
-		var user models.User
-		if err := config.DB.
-			Preload("Socials", "deleted_at IS NULL").
-			First(&user, "id = ?", session.UserID).Error; err != nil {
-
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
-			return
+		if options.requireMFA {
+			enabled, err := services.MFAEnabled(user.ID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check mfa"})
+				return
+			}
+			if !enabled {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "two-factor authentication required"})
+				return
+			}
 		}
 
-		// Attach user to request context
+		// Attach user and session (or access token) to request context
 		c.Set("user", user)
+		if session != nil {
+			if err := services.TouchSession(c, session); err != nil {
+				log.Printf("failed to update session activity: %v", err)
+			}
+			c.Set("session", *session)
+
+			if session.ImpersonatorID != nil {
+				c.Header(services.ImpersonationHeader, session.ImpersonatorID.String())
+				c.Set("impersonator_id", *session.ImpersonatorID)
+			}
+		}
+		if accessToken != nil {
+			c.Set("access_token", *accessToken)
+		}
 
 		c.Next()
 	}
 }
+
+// RequireSession rejects requests authenticated with an access token. Use it
+// for account security routes that scripts should never reach.
+// Must run after RequireAuth.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("session"); !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "browser session required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// BlockImpersonation rejects requests from an admin impersonating the user,
+// for actions staff must never take on someone's behalf.
+// Must run after RequireAuth.
+func BlockImpersonation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("impersonator_id"); ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// bearerToken extracts the credential from an "Authorization: Bearer" header
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok || token == "" {
+		return "", false
+	}
+	return strings.TrimSpace(token), true
+}
+
+func loadUser(user *models.User, id uuid.UUID) error {
+	return config.DB.
+		Preload("Socials", "deleted_at IS NULL").
+		First(user, "id = ?", id).Error
+}
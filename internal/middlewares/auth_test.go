@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"build-in-public/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireScope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Stands in for RequireAuth, attaching a personal access token if given one
+	authenticated := func(scopes []models.Scope) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			if scopes != nil {
+				c.Set("access_token", models.PersonalAccessToken{Scopes: scopes})
+			}
+			c.Next()
+		}
+	}
+
+	tests := []struct {
+		name   string
+		scopes []models.Scope
+		want   int
+	}{
+		{"browser session", nil, http.StatusOK},
+		{"token with the scope", []models.Scope{models.ScopeProfileRead, models.ScopePostsWrite}, http.StatusOK},
+		{"token without the scope", []models.Scope{models.ScopeProfileRead}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.POST("/posts", authenticated(tt.scopes), RequireScope(models.ScopePostsWrite), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/posts", nil))
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequireScopeDeclaresScopesForRequireAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var declared []models.Scope
+	r := gin.New()
+	r.GET("/posts",
+		RequireScope(models.ScopePostsRead),
+		RequireScope(models.ScopeProfileRead),
+		func(c *gin.Context) {
+			scopes, _ := c.Get(requiredScopesKey)
+			declared = asScopes(scopes)
+			c.Status(http.StatusOK)
+		},
+	)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/posts", nil))
+
+	want := []models.Scope{models.ScopePostsRead, models.ScopeProfileRead}
+	if w.Code != http.StatusOK || !slices.Equal(declared, want) {
+		t.Fatalf("status = %d, declared = %v, want 200 and %v", w.Code, declared, want)
+	}
+}
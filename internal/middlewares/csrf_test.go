@@ -0,0 +1,152 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	testFrontend  = "https://app.example.com"
+	testCSRFToken = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFG"
+)
+
+func TestCSRF(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("FRONTEND_URL", testFrontend)
+	t.Setenv("BASE_URL", "https://api.example.com")
+
+	r := gin.New()
+	r.Use(CSRF())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	r.GET("/users/me", ok)
+	r.POST("/users/me", ok)
+	r.POST("/auth/token", ok)
+	r.POST("/auth/token/revoke", ok)
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		cookie  string
+		want    int
+	}{
+		{
+			name:   "safe method",
+			method: http.MethodGet, path: "/users/me",
+			headers: map[string]string{"Origin": "https://evil.example"},
+			want:    http.StatusOK,
+		},
+		{
+			name:   "matching token from the frontend",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Origin": testFrontend, services.CSRFHeaderName: testCSRFToken},
+			cookie:  testCSRFToken,
+			want:    http.StatusOK,
+		},
+		{
+			name:   "missing header",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Origin": testFrontend},
+			cookie:  testCSRFToken,
+			want:    http.StatusForbidden,
+		},
+		{
+			name:   "missing cookie",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Origin": testFrontend, services.CSRFHeaderName: testCSRFToken},
+			want:    http.StatusForbidden,
+		},
+		{
+			name:   "mismatched header",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Origin": testFrontend, services.CSRFHeaderName: "another-token"},
+			cookie:  testCSRFToken,
+			want:    http.StatusForbidden,
+		},
+		{
+			name:   "null origin",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Origin": "null", services.CSRFHeaderName: testCSRFToken},
+			cookie:  testCSRFToken,
+			want:    http.StatusForbidden,
+		},
+		{
+			name:   "untrusted origin",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Origin": "https://evil.example", services.CSRFHeaderName: testCSRFToken},
+			cookie:  testCSRFToken,
+			want:    http.StatusForbidden,
+		},
+		{
+			name:   "trusted referer without origin",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Referer": testFrontend + "/settings", services.CSRFHeaderName: testCSRFToken},
+			cookie:  testCSRFToken,
+			want:    http.StatusOK,
+		},
+		{
+			name:   "untrusted referer without origin",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Referer": "https://evil.example/page", services.CSRFHeaderName: testCSRFToken},
+			cookie:  testCSRFToken,
+			want:    http.StatusForbidden,
+		},
+		{
+			name:   "origin wins over a trusted referer",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{
+				"Origin":                "https://evil.example",
+				"Referer":               testFrontend + "/settings",
+				services.CSRFHeaderName: testCSRFToken,
+			},
+			cookie: testCSRFToken,
+			want:   http.StatusForbidden,
+		},
+		{
+			name:   "non-browser client still needs the token",
+			method: http.MethodPost, path: "/users/me",
+			want: http.StatusForbidden,
+		},
+		{
+			name:   "bearer token",
+			method: http.MethodPost, path: "/users/me",
+			headers: map[string]string{"Origin": "https://evil.example", "Authorization": "Bearer bip_pat_x"},
+			want:    http.StatusOK,
+		},
+		{
+			name:   "token endpoint",
+			method: http.MethodPost, path: "/auth/token",
+			headers: map[string]string{"Origin": "https://evil.example"},
+			want:    http.StatusOK,
+		},
+		{
+			name:   "token revocation endpoint",
+			method: http.MethodPost, path: "/auth/token/revoke",
+			headers: map[string]string{"Origin": "https://evil.example"},
+			want:    http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: services.CSRFCookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKey picks what a request is counted against
+type RateLimitKey func(c *gin.Context) string
+
+// ByIP counts requests per client IP
+func ByIP(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// RateLimit rejects requests over the limiter's limit with 429 and a
+// Retry-After header
+func RateLimit(limiter *services.RateLimiter, key RateLimitKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter := limiter.Allow(key(c))
+		if !allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
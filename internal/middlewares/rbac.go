@@ -0,0 +1,57 @@
+package middleware
+
+import (
+	"net/http"
+	"slices"
+
+	"build-in-public/internal/models"
+	"build-in-public/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole rejects users whose role is not one of roles.
+// Must run after RequireAuth.
+func RequireRole(roles ...models.RoleName) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := contextUser(c)
+		if !ok {
+			return
+		}
+		if !slices.Contains(roles, user.Role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission rejects users whose role does not grant permission.
+// Must run after RequireAuth.
+func RequirePermission(permission models.PermissionName) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := contextUser(c)
+		if !ok {
+			return
+		}
+		if !services.HasPermission(user.Role, permission) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden", "required_permission": permission})
+			return
+		}
+		c.Next()
+	}
+}
+
+func contextUser(c *gin.Context) (models.User, bool) {
+	userAny, exists := c.Get("user")
+	if !exists {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthenticated"})
+		return models.User{}, false
+	}
+	user, ok := userAny.(models.User)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "invalid user type"})
+		return models.User{}, false
+	}
+	return user, true
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PersonalAccessToken lets scripts and the CLI call the API as a user.
+// Only the SHA-256 hash of the token is stored.
+type PersonalAccessToken struct {
+	ID         uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name       string    `gorm:"size:100;not null"`
+	TokenHash  string    `gorm:"size:64;not null;uniqueIndex"`
+	Hint       string    `gorm:"size:20;not null"` // first characters of the token, to tell tokens apart
+	Scopes     []Scope   `gorm:"type:jsonb;serializer:json;not null"`
+	LastUsedAt *time.Time
+	LastUsedIP string     `gorm:"size:45"`
+	ExpiresAt  *time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
+
+func (PersonalAccessToken) TableName() string {
+	return "personal_access_tokens"
+}
+
+// HasScope reports whether the token was granted scope
+func (t *PersonalAccessToken) HasScope(scope Scope) bool {
+	return slices.Contains(t.Scopes, scope)
+}
+
+// Expired reports whether the token has passed its expiry
+func (t *PersonalAccessToken) Expired(now time.Time) bool {
+	return t.ExpiresAt != nil && !t.ExpiresAt.After(now)
+}
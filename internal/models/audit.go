@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditEvent records a security relevant action. Rows are only ever
+// inserted; a database trigger rejects deletes, and updates other than
+// blanking IPAddress, UserAgent and Metadata when an account is purged.
+type AuditEvent struct {
+	ID        uuid.UUID      `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    *uuid.UUID     `gorm:"type:uuid;index"` // account the event is about
+	ActorID   *uuid.UUID     `gorm:"type:uuid;index"` // who performed it, when not the user themselves
+	Action    AuditAction    `gorm:"type:varchar(64);not null;index"`
+	IPAddress string         `gorm:"size:45"`
+	UserAgent string         `gorm:"size:512"`
+	Metadata  map[string]any `gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time      `gorm:"not null;index"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
@@ -3,6 +3,7 @@ package models
 type SocialPlatform string
 type Gender string
 type OAuthProvider string
+type TokenPurpose string
 
 const (
 	PlatformGithub   SocialPlatform = "github"
@@ -23,3 +24,79 @@ const (
 	OAuthLinkedIn  OAuthProvider = "linkedin"
 	OAuthMicrosoft OAuthProvider = "microsoft"
 )
+
+const (
+	TokenEmailVerification TokenPurpose = "email_verification"
+	TokenPasswordReset     TokenPurpose = "password_reset"
+	TokenMagicLink         TokenPurpose = "magic_link"
+	TokenAccountRestore    TokenPurpose = "account_restore"
+)
+
+// Scope limits what a personal access token may do
+type Scope string
+
+const (
+	ScopeProfileRead  Scope = "profile:read"
+	ScopeProfileWrite Scope = "profile:write"
+	ScopePostsRead    Scope = "posts:read"
+	ScopePostsWrite   Scope = "posts:write"
+)
+
+// Scopes lists every scope a token can be granted
+var Scopes = []Scope{ScopeProfileRead, ScopeProfileWrite, ScopePostsRead, ScopePostsWrite}
+
+// RoleName identifies a role; every user has exactly one
+type RoleName string
+
+const (
+	RoleUser         RoleName = "user"
+	RoleModerator    RoleName = "moderator"
+	RoleCollegeAdmin RoleName = "college-admin"
+	RoleAdmin        RoleName = "admin"
+)
+
+// PermissionName identifies something a role may do
+type PermissionName string
+
+const (
+	PermUsersRead        PermissionName = "users:read"
+	PermUsersSuspend     PermissionName = "users:suspend"
+	PermUsersAssignRole  PermissionName = "users:assign_role"
+	PermContentModerate  PermissionName = "content:moderate"
+	PermCollegeManage    PermissionName = "college:manage"
+	PermAuditRead        PermissionName = "audit:read"
+	PermUsersImpersonate PermissionName = "users:impersonate"
+)
+
+// AuditAction names a security relevant event
+type AuditAction string
+
+const (
+	AuditSignup               AuditAction = "signup"
+	AuditLogin                AuditAction = "login"
+	AuditLoginFailed          AuditAction = "login_failed"
+	AuditAccountLocked        AuditAction = "account_locked"
+	AuditLogout               AuditAction = "logout"
+	AuditRefreshTokenReused   AuditAction = "refresh_token_reused"
+	AuditOAuthLinked          AuditAction = "oauth_linked"
+	AuditOAuthUnlinked        AuditAction = "oauth_unlinked"
+	AuditPasswordChanged      AuditAction = "password_changed"
+	AuditPasswordReset        AuditAction = "password_reset"
+	AuditSessionRevoked       AuditAction = "session_revoked"
+	AuditSessionsRevoked      AuditAction = "sessions_revoked"
+	AuditMFAEnabled           AuditAction = "mfa_enabled"
+	AuditMFADisabled          AuditAction = "mfa_disabled"
+	AuditPhoneChanged         AuditAction = "phone_changed"
+	AuditPhoneVerified        AuditAction = "phone_verified"
+	AuditAccessTokenCreated   AuditAction = "access_token_created"
+	AuditAccessTokenRevoked   AuditAction = "access_token_revoked"
+	AuditRoleAssigned         AuditAction = "role_assigned"
+	AuditUserSuspended        AuditAction = "user_suspended"
+	AuditUserUnsuspended      AuditAction = "user_unsuspended"
+	AuditDataExported         AuditAction = "data_exported"
+	AuditAccountDeleted       AuditAction = "account_deleted"
+	AuditAccountRestored      AuditAction = "account_restored"
+	AuditAccountPurged        AuditAction = "account_purged"
+	AuditImpersonationStarted AuditAction = "impersonation_started"
+	AuditImpersonationStopped AuditAction = "impersonation_stopped"
+)
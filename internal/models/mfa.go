@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TOTPCredential holds a user's authenticator app secret. It only counts as a
+// second factor once ConfirmedAt is set by a successful confirmation code.
+type TOTPCredential struct {
+	ID          uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID      uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	Secret      string    `gorm:"size:64;not null"`
+	LastCounter int64     `gorm:"not null;default:0"`
+	ConfirmedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (TOTPCredential) TableName() string {
+	return "totp_credentials"
+}
+
+type RecoveryCode struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	CodeHash  string    `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallenge is the short-lived state between a correct password and a
+// correct second factor. It never grants access on its own.
+type MFAChallenge struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OAuthState is the server side half of an in-flight OAuth authorization.
+// It is looked up by the hash of the state parameter and deleted on first use.
+type OAuthState struct {
+	ID           uuid.UUID     `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	StateHash    string        `gorm:"size:64;not null;uniqueIndex"`
+	Provider     OAuthProvider `gorm:"type:varchar(50);not null"`
+	CodeVerifier string        `gorm:"size:128;not null"`
+	Nonce        string        `gorm:"size:64;not null"`
+	ReturnTo     string        `gorm:"size:500"`
+	LinkUserID   *uuid.UUID    `gorm:"type:uuid"` // set when a signed-in user is linking the provider
+	ExpiresAt    time.Time     `gorm:"not null;index"`
+	CreatedAt    time.Time
+}
+
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PhoneVerification is the pending one-time code sent to a user's phone.
+// Each user has at most one; sending a new code replaces it.
+type PhoneVerification struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;uniqueIndex"`
+	Phone     string    `gorm:"size:20;not null"`
+	CodeHash  string    `gorm:"size:64;not null"`
+	Attempts  int       `gorm:"not null;default:0"`
+	ExpiresAt time.Time `gorm:"not null"`
+	SentAt    time.Time `gorm:"not null"`
+	CreatedAt time.Time
+}
+
+func (PhoneVerification) TableName() string {
+	return "phone_verifications"
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RateLimitBucket counts events for one key in one fixed window
+type RateLimitBucket struct {
+	Key         string    `gorm:"size:255;primaryKey"`
+	WindowStart time.Time `gorm:"primaryKey"`
+	Count       int       `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+func (RateLimitBucket) TableName() string {
+	return "rate_limit_buckets"
+}
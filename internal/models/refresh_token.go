@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RefreshToken is one link in a rotation chain. Every refresh marks the
+// presented token used and issues its successor in the same family, so a used
+// token showing up again means it was copied and the family is revoked.
+// Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
+	FamilyID  uuid.UUID `gorm:"type:uuid;not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	IPAddress string    `gorm:"size:45"`
+	UserAgent string    `gorm:"type:text"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Role groups the permissions granted to the users holding it
+type Role struct {
+	Name        RoleName     `gorm:"type:varchar(50);primaryKey"`
+	Description string       `gorm:"size:255"`
+	Permissions []Permission `gorm:"many2many:role_permissions;joinForeignKey:RoleName;joinReferences:PermissionName"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (Role) TableName() string {
+	return "roles"
+}
+
+type Permission struct {
+	Name        PermissionName `gorm:"type:varchar(50);primaryKey"`
+	Description string         `gorm:"size:255"`
+	CreatedAt   time.Time
+}
+
+func (Permission) TableName() string {
+	return "permissions"
+}
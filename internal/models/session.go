@@ -7,8 +7,15 @@ import (
 )
 
 type Session struct {
-	ID        uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null;index"`
-	ExpiresAt time.Time `gorm:"not null"`
-	CreatedAt time.Time
+	ID                    uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID                uuid.UUID `gorm:"type:uuid;not null;index"`
+	IPAddress             string    `gorm:"size:45"`
+	UserAgent             string    `gorm:"type:text"`
+	DeviceLabel           string    `gorm:"size:255"`
+	LastSeenAt            time.Time
+	ExpiresAt             time.Time `gorm:"not null"`
+	AbsoluteExpiresAt     time.Time
+	ImpersonatorID        *uuid.UUID `gorm:"type:uuid;index"` // admin viewing the app as this user
+	ImpersonatorSessionID *uuid.UUID `gorm:"type:uuid"`       // the admin's own session, restored when impersonation stops
+	CreatedAt             time.Time
 }
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// UserToken is a single-use secret mailed to a user. Only the SHA-256 hash of the
+// token is stored; the raw value exists solely in the link we send out.
+type UserToken struct {
+	ID        uuid.UUID    `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID    uuid.UUID    `gorm:"type:uuid;not null;index"`
+	Purpose   TokenPurpose `gorm:"type:varchar(50);not null;index"`
+	TokenHash string       `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time    `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
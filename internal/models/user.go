@@ -32,43 +32,59 @@ type SocialAccount struct {
 }
 
 type OAuthAccount struct {
-	ID           uuid.UUID     `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
-	UserID       uuid.UUID     `gorm:"type:uuid;not null;index"`
-	Provider     OAuthProvider `gorm:"type:varchar(50);not null;uniqueIndex:idx_provider_uid"`
-	ProviderUID  string        `gorm:"size:255;not null;uniqueIndex:idx_provider_uid"`
-	Email        string        `gorm:"size:255"`
-	AvatarURL    string        `gorm:"size:500"`
-	AccessToken  string        `gorm:"type:text"`
-	RefreshToken string        `gorm:"type:text"`
-	ExpiresAt    *time.Time
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	ID             uuid.UUID     `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID         uuid.UUID     `gorm:"type:uuid;not null;index"`
+	Provider       OAuthProvider `gorm:"type:varchar(50);not null;uniqueIndex:idx_provider_uid"`
+	ProviderUID    string        `gorm:"size:255;not null;uniqueIndex:idx_provider_uid"`
+	Email          string        `gorm:"size:255"`
+	AvatarURL      string        `gorm:"size:500"`
+	AccessToken    string        `gorm:"type:text;serializer:encrypted"`
+	RefreshToken   string        `gorm:"type:text;serializer:encrypted"`
+	ExpiresAt      *time.Time
+	ReauthRequired bool       `gorm:"not null;default:false"`
+	LastUsedAt     *time.Time // last sign-in or API call; idle accounts are not refreshed in the background
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
 }
 
 func (OAuthAccount) TableName() string {
 	return "oauth_accounts"
 }
 
+// BeforeCreate assigns the ID up front, as the encrypted token columns are bound to it
+func (a *OAuthAccount) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	return nil
+}
+
 type User struct {
-	ID            uuid.UUID       `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
-	FirstName     string          `gorm:"size:255;not null" json:"first_name"`
-	LastName      *string         `gorm:"size:255;" json:"last_name"`
-	Email         string          `gorm:"uniqueIndex;not null" json:"email"`
-	EmailVerified bool            `gorm:"not null" json:"email_verified"`
-	Username      *string         `gorm:"size:255;" json:"username"`
-	Phone         *string         `gorm:"size:20" json:"phone"`
-	PhoneVerified bool            `gorm:"not null" json:"phone_verified"`
-	Gender        Gender          `gorm:"type:varchar(10);not null" json:"gender"`
-	DateOfBirth   *time.Time      `gorm:"type:date" json:"date_of_birth"`
-	City          *string         `gorm:"size:255" json:"city"`
-	Bio           *string         `gorm:"size:255" json:"bio"`
-	Password      *string         `json:"-"`
-	OAuthAccounts []OAuthAccount  `gorm:"foreignKey:UserID" json:"oauth_accounts"`
-	Socials       []SocialAccount `gorm:"foreignKey:UserID" json:"socials"`
-	College       *College        `gorm:"foreignKey:CollegeID" json:"college"`
-	CollegeID     *uuid.UUID      `gorm:"type:uuid" json:"college_id"`
-	CreatedAt     time.Time       `json:"created_at"`
-	UpdatedAt     time.Time       `json:"updated_at"`
-	DeletedAt     gorm.DeletedAt  `gorm:"index" json:"-"`
+	ID               uuid.UUID            `gorm:"type:uuid;default:gen_random_uuid();primaryKey" json:"id"`
+	FirstName        string               `gorm:"size:255;not null" json:"first_name"`
+	LastName         *string              `gorm:"size:255;" json:"last_name"`
+	Email            string               `gorm:"uniqueIndex;not null" json:"email"`
+	EmailVerified    bool                 `gorm:"not null" json:"email_verified"`
+	Username         *string              `gorm:"size:255;" json:"username"`
+	Phone            *string              `gorm:"size:20" json:"phone"`
+	PhoneVerified    bool                 `gorm:"not null" json:"phone_verified"`
+	Gender           Gender               `gorm:"type:varchar(10);not null" json:"gender"`
+	DateOfBirth      *time.Time           `gorm:"type:date" json:"date_of_birth"`
+	City             *string              `gorm:"size:255" json:"city"`
+	Bio              *string              `gorm:"size:255" json:"bio"`
+	Password         *string              `json:"-"`
+	Role             RoleName             `gorm:"type:varchar(50);not null;default:user;index" json:"role"`
+	SuspendedAt      *time.Time           `json:"-"`
+	SuspendedReason  string               `gorm:"size:500" json:"-"`
+	FailedLoginCount int                  `gorm:"not null;default:0" json:"-"`
+	LockedUntil      *time.Time           `json:"-"`
+	OAuthAccounts    []OAuthAccount       `gorm:"foreignKey:UserID" json:"oauth_accounts"`
+	Socials          []SocialAccount      `gorm:"foreignKey:UserID" json:"socials"`
+	Passkeys         []WebAuthnCredential `gorm:"foreignKey:UserID" json:"-"`
+	College          *College             `gorm:"foreignKey:CollegeID" json:"college"`
+	CollegeID        *uuid.UUID           `gorm:"type:uuid" json:"college_id"`
+	CreatedAt        time.Time            `json:"created_at"`
+	UpdatedAt        time.Time            `json:"updated_at"`
+	DeletedAt        gorm.DeletedAt       `gorm:"index" json:"-"`
 }
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebAuthnCredential is a passkey registered by a user
+type WebAuthnCredential struct {
+	ID              uuid.UUID `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name            string    `gorm:"size:255;not null"`
+	CredentialID    []byte    `gorm:"type:bytea;not null;uniqueIndex"`
+	PublicKey       []byte    `gorm:"type:bytea;not null"`
+	AttestationType string    `gorm:"size:50"`
+	Transports      string    `gorm:"size:255"`
+	AAGUID          []byte    `gorm:"type:bytea"`
+	SignCount       uint32    `gorm:"type:bigint;not null;default:0"`
+	CloneWarning    bool      `gorm:"not null;default:false"`
+	BackupEligible  bool      `gorm:"not null;default:false"`
+	BackupState     bool      `gorm:"not null;default:false"`
+	LastUsedAt      *time.Time
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+}
+
+func (WebAuthnCredential) TableName() string {
+	return "webauthn_credentials"
+}
+
+// WebAuthnCeremony keeps the server side challenge of an in-flight passkey
+// registration or login between its begin and finish requests
+type WebAuthnCeremony struct {
+	ID          uuid.UUID  `gorm:"type:uuid;default:gen_random_uuid();primaryKey"`
+	UserID      *uuid.UUID `gorm:"type:uuid;index"`
+	Purpose     string     `gorm:"size:20;not null"`
+	SessionData string     `gorm:"type:text;not null"`
+	ExpiresAt   time.Time  `gorm:"not null"`
+	CreatedAt   time.Time
+}
+
+func (WebAuthnCeremony) TableName() string {
+	return "webauthn_ceremonies"
+}
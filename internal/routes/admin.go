@@ -0,0 +1,28 @@
+package routes
+
+import (
+	"build-in-public/internal/handlers"
+	middleware "build-in-public/internal/middlewares"
+	"build-in-public/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RegisterAdminRoutes(r *gin.Engine) {
+	// Staff only, from a browser session with two-factor enrolled
+	admin := r.Group("/admin")
+	admin.Use(
+		middleware.RequireAuth(middleware.WithMFA()),
+		middleware.RequireSession(),
+		middleware.RequireRole(models.RoleModerator, models.RoleCollegeAdmin, models.RoleAdmin),
+	)
+	{
+		admin.GET("/users", middleware.RequirePermission(models.PermUsersRead), handlers.AdminListUsers)
+		admin.POST("/users/:id/suspend", middleware.RequirePermission(models.PermUsersSuspend), handlers.AdminSuspendUser)
+		admin.POST("/users/:id/unsuspend", middleware.RequirePermission(models.PermUsersSuspend), handlers.AdminUnsuspendUser)
+		admin.PUT("/users/:id/role", middleware.RequirePermission(models.PermUsersAssignRole), handlers.AdminAssignRole)
+		admin.POST("/users/:id/impersonate", middleware.RequirePermission(models.PermUsersImpersonate), handlers.AdminImpersonateUser)
+
+		admin.GET("/audit-events", middleware.RequirePermission(models.PermAuditRead), handlers.AdminListAuditEvents)
+	}
+}
@@ -1,29 +1,63 @@
 package routes
 
 import (
+	"time"
+
 	"build-in-public/internal/handlers"
+	middleware "build-in-public/internal/middlewares"
+	"build-in-public/internal/services"
 
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	loginIPLimiter       = services.NewRateLimiter("login-ip", 30, 15*time.Minute)
+	signupIPLimiter      = services.NewRateLimiter("signup-ip", 10, time.Hour)
+	mfaVerifyIPLimiter   = services.NewRateLimiter("mfa-verify-ip", 30, 15*time.Minute)
+	passwordResetLimiter = services.NewRateLimiter("password-reset-ip", 10, 15*time.Minute)
+)
+
 func RegisterAuthRoutes(r *gin.Engine) {
+	r.GET("/.well-known/jwks.json", handlers.JWKS)
+
 	auth := r.Group("/auth")
 	{
+		auth.GET("/csrf", handlers.GetCSRFToken)
+
 		// Traditional auth
-		auth.POST("/signup", handlers.Signup)
-		auth.POST("/login", handlers.Login)
+		auth.POST("/signup", middleware.RateLimit(signupIPLimiter, middleware.ByIP), handlers.Signup)
+		auth.POST("/login", middleware.RateLimit(loginIPLimiter, middleware.ByIP), handlers.Login)
 		auth.POST("/logout", handlers.Logout)
+		auth.POST("/token", middleware.RateLimit(loginIPLimiter, middleware.ByIP), handlers.IssueToken)
+		auth.POST("/token/revoke", handlers.RevokeToken)
+		auth.POST("/mfa/verify", middleware.RateLimit(mfaVerifyIPLimiter, middleware.ByIP), handlers.VerifyMFA)
+
+		// Passkeys
+		auth.POST("/passkey/begin", middleware.RateLimit(loginIPLimiter, middleware.ByIP), handlers.BeginPasskeyLogin)
+		auth.POST("/passkey/finish", middleware.RateLimit(loginIPLimiter, middleware.ByIP), handlers.FinishPasskeyLogin)
+
+		// Magic link
+		auth.POST("/magic-link", handlers.RequestMagicLink)
+		auth.GET("/magic-link/consume", handlers.MagicLinkLanding)
+		auth.POST("/magic-link/consume", middleware.RateLimit(loginIPLimiter, middleware.ByIP), handlers.ConsumeMagicLink)
+
+		// Email verification
+		auth.POST("/verify-email/request", middleware.RateLimit(passwordResetLimiter, middleware.ByIP), middleware.RequireAuth(), handlers.RequestEmailVerification)
+		auth.GET("/verify-email/confirm", handlers.ConfirmEmailVerification)
+
+		// Password reset
+		auth.POST("/password/forgot", middleware.RateLimit(passwordResetLimiter, middleware.ByIP), handlers.ForgotPassword)
+		auth.POST("/password/reset", middleware.RateLimit(passwordResetLimiter, middleware.ByIP), handlers.ResetPassword)
 
-		// OAuth - Google
-		auth.GET("/google", handlers.GoogleLogin)
-		auth.GET("/google/callback", handlers.GoogleCallback)
+		// Impersonating admins switch back from here, as the admin area rejects the user's role
+		auth.POST("/impersonation/stop", middleware.RequireAuth(), middleware.RequireSession(), handlers.StopImpersonation)
 
-		// OAuth - GitHub
-		auth.GET("/github", handlers.GitHubLogin)
-		auth.GET("/github/callback", handlers.GitHubCallback)
+		// Undo an account deletion during its grace period
+		auth.POST("/account/restore", middleware.RateLimit(passwordResetLimiter, middleware.ByIP), handlers.RestoreAccount)
 
-		// OAuth - LinkedIn
-		auth.GET("/linkedin", handlers.LinkedInLogin)
-		auth.GET("/linkedin/callback", handlers.LinkedInCallback)
+		// OAuth
+		auth.GET("/:provider", handlers.OAuthLogin)
+		auth.GET("/:provider/callback", handlers.OAuthCallback)
+		auth.GET("/:provider/link", middleware.RequireAuth(middleware.WithVerifiedEmail()), middleware.RequireSession(), middleware.BlockImpersonation(), handlers.OAuthLink)
 	}
 }
@@ -3,15 +3,72 @@ package routes
 import (
 	"build-in-public/internal/handlers"
 	middleware "build-in-public/internal/middlewares"
+	"build-in-public/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
 func RegisterUserRoutes(r *gin.Engine) {
 	users := r.Group("/users")
-	users.Use(middleware.RequireAuth())
 	{
-		users.GET("/me", handlers.Me)
+		// Reachable with a personal access token that has the scope
+		users.GET("/me", middleware.RequireScope(models.ScopeProfileRead), middleware.RequireAuth(), handlers.Me)
 	}
 
+	// Account security is managed from the browser only
+	account := users.Group("/me")
+	account.Use(middleware.RequireAuth(), middleware.RequireSession())
+	// Impersonating admins may look but never change how the user signs in
+	sensitive := middleware.BlockImpersonation()
+	{
+		account.GET("/export", sensitive, handlers.ExportAccount)
+		account.GET("/sessions", handlers.ListSessions)
+		account.GET("/sessions/:id", handlers.GetSession)
+		account.GET("/mfa", handlers.GetMFAStatus)
+		account.GET("/passkeys", handlers.ListPasskeys)
+		account.GET("/security-events", handlers.ListSecurityEvents)
+		account.GET("/tokens", handlers.ListAccessTokens)
+
+		// Deleting the account stays open to unverified users, so a signup
+		// under a mistyped address can still be removed
+		account.DELETE("", sensitive, handlers.DeleteAccount)
+	}
+
+	// Changes to the account require a confirmed email address
+	manage := r.Group("/users/me")
+	manage.Use(
+		middleware.RequireAuth(middleware.WithVerifiedEmail()),
+		middleware.RequireSession(),
+		sensitive,
+	)
+	{
+		manage.PUT("/password", handlers.ChangePassword)
+
+		// Sessions
+		manage.DELETE("/sessions", handlers.RevokeOtherSessions)
+		manage.DELETE("/sessions/:id", handlers.RevokeSession)
+
+		// Two-factor authentication
+		manage.POST("/mfa/totp", handlers.BeginTOTPEnrolment)
+		manage.POST("/mfa/totp/confirm", handlers.ConfirmTOTPEnrolment)
+		manage.DELETE("/mfa/totp", handlers.DisableTOTP)
+		manage.POST("/mfa/recovery-codes", handlers.RegenerateRecoveryCodes)
+
+		// Passkeys
+		manage.POST("/passkeys/register/begin", handlers.BeginPasskeyRegistration)
+		manage.POST("/passkeys/register/finish", handlers.FinishPasskeyRegistration)
+		manage.DELETE("/passkeys/:id", handlers.DeletePasskey)
+
+		// Linked OAuth providers
+		manage.DELETE("/oauth/:provider", handlers.UnlinkOAuthProvider)
+
+		// Phone verification
+		manage.POST("/phone", handlers.SetPhone)
+		manage.POST("/phone/otp", handlers.SendPhoneOTP)
+		manage.POST("/phone/verify", handlers.VerifyPhone)
+
+		// Personal access tokens
+		manage.POST("/tokens", handlers.CreateAccessToken)
+		manage.DELETE("/tokens/:id", handlers.DeleteAccessToken)
+	}
 }
@@ -0,0 +1,167 @@
+package routes_test
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"build-in-public/internal/models"
+	"build-in-public/internal/routes"
+	"build-in-public/internal/services"
+	"build-in-public/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMutatingUserRoutesRequireVerifiedEmail(t *testing.T) {
+	testutil.DB(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	routes.RegisterUserRoutes(r)
+
+	tests := []struct {
+		name     string
+		verified bool
+		want     int
+	}{
+		{"unverified user is rejected", false, http.StatusForbidden},
+		// Passing the gate lands in the handler, which rejects the empty body
+		{"verified user reaches the handler", true, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := testutil.CreateUser(t, func(u *models.User) {
+				u.EmailVerified = tt.verified
+			})
+			session := testutil.CreateSession(t, user.ID)
+
+			req := httptest.NewRequest(http.MethodPut, "/users/me/password", strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.AddCookie(&http.Cookie{Name: services.SessionCookieName, Value: session.ID.String()})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPersonalAccessTokensNeedADeclaredScope(t *testing.T) {
+	testutil.DB(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	routes.RegisterUserRoutes(r)
+
+	user := testutil.CreateUser(t, nil)
+	// Even a token holding every scope only reaches routes that declare one
+	raw, _, err := services.CreatePersonalAccessToken(user.ID, "ci", models.Scopes, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   int
+	}{
+		{http.MethodPost, "/users/me/tokens", http.StatusForbidden},
+		{http.MethodDelete, "/users/me/sessions", http.StatusForbidden},
+		{http.MethodGet, "/users/me", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader("{}"))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer "+raw)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestPersonalAccessTokensNeedTheRouteScope(t *testing.T) {
+	testutil.DB(t)
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	routes.RegisterUserRoutes(r)
+
+	user := testutil.CreateUser(t, nil)
+	tests := []struct {
+		name   string
+		scopes []models.Scope
+		want   int
+	}{
+		{"granted profile:read", []models.Scope{models.ScopeProfileRead}, http.StatusOK},
+		{"granted other scopes", []models.Scope{models.ScopePostsRead, models.ScopePostsWrite}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw, _, err := services.CreatePersonalAccessToken(user.ID, tt.name, tt.scopes, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+			req.Header.Set("Authorization", "Bearer "+raw)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAccessTokensStopWorkingWhenTheirFamilyIsRevoked(t *testing.T) {
+	testutil.DB(t)
+	gin.SetMode(gin.TestMode)
+	t.Setenv("JWT_SIGNING_KEYS", "test:"+base64.StdEncoding.EncodeToString(make([]byte, 32)))
+	services.InitTokenSigning()
+
+	r := gin.New()
+	routes.RegisterUserRoutes(r)
+
+	user := testutil.CreateUser(t, nil)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/token", nil)
+	refresh, family, err := services.IssueRefreshToken(c, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, _, err := services.SignAccessToken(user.ID, family.FamilyID, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/users/me", nil)
+		req.Header.Set("Authorization", "Bearer "+access)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if got := get(); got != http.StatusOK {
+		t.Fatalf("before revocation: status = %d, want 200", got)
+	}
+	if _, err := services.RevokeRefreshToken(refresh); err != nil {
+		t.Fatal(err)
+	}
+	// The token has not expired, but the device it belongs to is signed out
+	if got := get(); got != http.StatusUnauthorized {
+		t.Fatalf("after revocation: status = %d, want 401", got)
+	}
+}
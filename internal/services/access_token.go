@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PersonalAccessTokenPrefix marks our tokens so they can be recognised in
+// Authorization headers and by secret scanners
+const PersonalAccessTokenPrefix = "bip_pat_"
+
+var ErrInvalidAccessToken = errors.New("invalid or expired access token")
+
+// CreatePersonalAccessToken issues a token for the user. The raw token is
+// returned once; only its hash is stored. A nil expiresAt never expires.
+func CreatePersonalAccessToken(userID uuid.UUID, name string, scopes []models.Scope, expiresAt *time.Time) (string, *models.PersonalAccessToken, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	raw := PersonalAccessTokenPrefix + secret
+
+	token := &models.PersonalAccessToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: HashToken(raw),
+		Hint:      raw[:len(PersonalAccessTokenPrefix)+4],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := config.DB.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, token, nil
+}
+
+// IsPersonalAccessToken reports whether a bearer credential looks like one of our tokens
+func IsPersonalAccessToken(raw string) bool {
+	return strings.HasPrefix(raw, PersonalAccessTokenPrefix)
+}
+
+// AuthenticatePersonalAccessToken resolves a raw token and records its use
+func AuthenticatePersonalAccessToken(raw, ip string) (*models.PersonalAccessToken, error) {
+	if !IsPersonalAccessToken(raw) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	var token models.PersonalAccessToken
+	if err := config.DB.Where("token_hash = ?", HashToken(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAccessToken
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.Expired(now) {
+		return nil, ErrInvalidAccessToken
+	}
+
+	// Like sessions, last use is only written once a minute per token
+	if token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= lastSeenInterval || token.LastUsedIP != ip {
+		token.LastUsedAt = &now
+		token.LastUsedIP = ip
+		config.DB.Model(&token).Updates(map[string]any{
+			"last_used_at": now,
+			"last_used_ip": ip,
+		})
+	}
+
+	return &token, nil
+}
+
+// ValidScope reports whether scope is one tokens can be granted
+func ValidScope(scope models.Scope) bool {
+	return slices.Contains(models.Scopes, scope)
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultDeletionGrace = 30 * 24 * time.Hour
+	purgeBatchSize       = 100
+)
+
+// AccountDeletionGrace is how long a deleted account can still be restored
+// before it is purged for good
+var AccountDeletionGrace = defaultDeletionGrace
+
+// InitAccountDeletion reads the grace period for account deletion
+func InitAccountDeletion() {
+	AccountDeletionGrace = durationFromEnv("ACCOUNT_DELETION_GRACE_PERIOD", defaultDeletionGrace)
+}
+
+// ScheduleAccountDeletion deactivates the account straight away: every
+// session and token is revoked or wiped and the user, their OAuth and social
+// accounts are soft deleted. The rest of their data stays until the purge, so
+// the returned restore token can undo the deletion within the grace period.
+func ScheduleAccountDeletion(userID uuid.UUID) (string, time.Time, error) {
+	var token string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := RevokeSessions(tx, userID, uuid.Nil); err != nil {
+			return err
+		}
+
+		for _, model := range []any{
+			&models.PersonalAccessToken{},
+			&models.UserToken{},
+			&models.MFAChallenge{},
+			&models.WebAuthnCeremony{},
+			&models.PhoneVerification{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("link_user_id = ?", userID).Delete(&models.OAuthState{}).Error; err != nil {
+			return err
+		}
+
+		// Provider grants are useless once the account is gone, and must not
+		// outlive it if the database leaks
+		if err := tx.Model(&models.OAuthAccount{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]any{
+				"access_token":    "",
+				"refresh_token":   "",
+				"expires_at":      nil,
+				"reauth_required": true,
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userID).Delete(&models.OAuthAccount{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.SocialAccount{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.User{}, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		// With the deletion, so a failure cannot leave the account deleted
+		// with no way to restore it
+		var err error
+		token, err = IssueUserTokenTx(tx, userID, models.TokenAccountRestore, AccountDeletionGrace)
+		return err
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, time.Now().Add(AccountDeletionGrace), nil
+}
+
+// RestoreAccount reactivates an account deleted within the grace period. The
+// user has to sign in again; linked providers ask for consent on next use.
+func RestoreAccount(raw string) (*models.User, error) {
+	var user models.User
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		token, err := ConsumeUserTokenTx(tx, raw, models.TokenAccountRestore)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().
+			Where("id = ? AND deleted_at IS NOT NULL", token.UserID).
+			First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidToken
+			}
+			return err
+		}
+		deletedAt := user.DeletedAt.Time
+
+		if err := tx.Unscoped().Model(&models.User{}).
+			Where("id = ?", user.ID).
+			Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		// Only what the deletion took down, not accounts unlinked before it
+		for _, model := range []any{&models.OAuthAccount{}, &models.SocialAccount{}} {
+			if err := tx.Unscoped().Model(model).
+				Where("user_id = ? AND deleted_at >= ?", user.ID, deletedAt).
+				Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	user.DeletedAt = gorm.DeletedAt{}
+	return &user, nil
+}
+
+// PendingDeletion reports whether email belongs to a deleted account that has
+// not been purged yet, and when it will be. Until then the address stays
+// taken, so the owner can still restore the account.
+func PendingDeletion(email string) (time.Time, bool) {
+	var user models.User
+	if err := config.DB.Unscoped().
+		Where("email = ? AND deleted_at IS NOT NULL", email).
+		First(&user).Error; err != nil {
+		return time.Time{}, false
+	}
+	return user.DeletedAt.Time.Add(AccountDeletionGrace), true
+}
+
+// ClaimUnverifiedAccount marks the account's email verified for someone who
+// just proved they own the address. Whoever signed up with it may not have,
+// so everything they could sign in with goes in the same transaction: the
+// password, sessions, refresh and personal access tokens, linked providers,
+// passkeys and authenticators. It reports false, changing nothing, when the
+// address was already verified.
+func ClaimUnverifiedAccount(userID uuid.UUID) (bool, error) {
+	claimed := false
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND email_verified = ?", userID, false).
+			Updates(map[string]any{"email_verified": true, "password": nil})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		claimed = true
+
+		if err := RevokeSessions(tx, userID, uuid.Nil); err != nil {
+			return err
+		}
+		for _, model := range []any{
+			&models.OAuthAccount{},
+			&models.PersonalAccessToken{},
+			&models.WebAuthnCredential{},
+			&models.WebAuthnCeremony{},
+			&models.TOTPCredential{},
+			&models.RecoveryCode{},
+			&models.MFAChallenge{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("link_user_id = ?", userID).Delete(&models.OAuthState{}).Error
+	})
+	return claimed, err
+}
+
+// StartAccountPurger hard deletes accounts whose grace period has passed.
+// ACCOUNT_PURGE_INTERVAL sets how often it runs.
+func StartAccountPurger(ctx context.Context) {
+	interval := durationFromEnv("ACCOUNT_PURGE_INTERVAL", time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := PurgeDeletedAccounts(ctx, time.Now()); err != nil {
+					log.Println("⚠️ Failed to purge deleted accounts:", err)
+				}
+			}
+		}
+	}()
+}
+
+// PurgeDeletedAccounts permanently removes every account deleted more than
+// AccountDeletionGrace before now, with all rows that belong to it. The audit
+// log keeps the account's events, stripped of personal data, so the record of
+// what happened survives under an ID that no longer leads to anyone.
+func PurgeDeletedAccounts(ctx context.Context, now time.Time) (int, error) {
+	purged := 0
+	for {
+		var ids []uuid.UUID
+		if err := config.DB.WithContext(ctx).Unscoped().
+			Model(&models.User{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", now.Add(-AccountDeletionGrace)).
+			Limit(purgeBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			return purged, nil
+		}
+
+		for _, id := range ids {
+			if err := purgeAccount(ctx, id); err != nil {
+				return purged, err
+			}
+			purged++
+			WriteAuditEvent(&models.AuditEvent{UserID: &id, Action: models.AuditAccountPurged})
+		}
+	}
+}
+
+func purgeAccount(ctx context.Context, userID uuid.UUID) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped()
+		for _, model := range []any{
+			&models.Session{},
+			&models.RefreshToken{},
+			&models.PersonalAccessToken{},
+			&models.UserToken{},
+			&models.TOTPCredential{},
+			&models.RecoveryCode{},
+			&models.MFAChallenge{},
+			&models.WebAuthnCredential{},
+			&models.WebAuthnCeremony{},
+			&models.PhoneVerification{},
+			&models.OAuthAccount{},
+			&models.SocialAccount{},
+		} {
+			if err := tx.Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("link_user_id = ?", userID).Delete(&models.OAuthState{}).Error; err != nil {
+			return err
+		}
+		if err := pseudonymiseAuditEvents(tx, userID); err != nil {
+			return err
+		}
+		return tx.Delete(&models.User{}, "id = ?", userID).Error
+	})
+}
+
+// pseudonymiseAuditEvents blanks the personal data in the user's audit events:
+// the IP address and user agent of requests they made, and the metadata of
+// events about them. The audit log trigger only allows this while
+// app.audit_purge is set, which SET LOCAL limits to this transaction.
+func pseudonymiseAuditEvents(tx *gorm.DB, userID uuid.UUID) error {
+	if err := tx.Exec("SET LOCAL app.audit_purge = 'on'").Error; err != nil {
+		return err
+	}
+
+	// Requests come from the actor, or from the user when there is none
+	if err := tx.Model(&models.AuditEvent{}).
+		Where("actor_id = ? OR (user_id = ? AND actor_id IS NULL)", userID, userID).
+		Updates(map[string]any{"ip_address": "", "user_agent": ""}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&models.AuditEvent{}).
+		Where("user_id = ?", userID).
+		Update("metadata", nil).Error
+}
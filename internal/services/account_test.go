@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+	"build-in-public/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+func TestPurgeDeletedAccountsPseudonymisesAuditEvents(t *testing.T) {
+	testutil.DB(t)
+	user := testutil.CreateUser(t, nil)
+	admin := testutil.CreateUser(t, nil)
+
+	own := models.AuditEvent{
+		UserID:    &user.ID,
+		Action:    models.AuditAccountPurged,
+		IPAddress: "203.0.113.7",
+		UserAgent: "Firefox",
+		Metadata:  map[string]any{"email": user.Email},
+	}
+	byAdmin := models.AuditEvent{
+		UserID:    &user.ID,
+		ActorID:   &admin.ID,
+		Action:    models.AuditAccountPurged,
+		IPAddress: "198.51.100.1",
+		UserAgent: "Chrome",
+		Metadata:  map[string]any{"reason": "spam"},
+	}
+	for _, event := range []*models.AuditEvent{&own, &byAdmin} {
+		if err := config.DB.Create(event).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Outside a purge the log cannot be edited
+	if err := config.DB.Model(&own).Update("ip_address", "").Error; err == nil {
+		t.Fatal("audit event updated outside a purge")
+	}
+
+	if err := config.DB.Delete(&models.User{}, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	purged, err := PurgeDeletedAccounts(context.Background(), time.Now().Add(AccountDeletionGrace+time.Hour))
+	if err != nil {
+		t.Fatalf("PurgeDeletedAccounts: %v", err)
+	}
+	if purged != 1 {
+		t.Fatalf("purged %d accounts, want 1", purged)
+	}
+
+	load := func(id uuid.UUID) models.AuditEvent {
+		t.Helper()
+		var event models.AuditEvent
+		if err := config.DB.First(&event, "id = ?", id).Error; err != nil {
+			t.Fatalf("audit event %s: %v", id, err)
+		}
+		return event
+	}
+
+	got := load(own.ID)
+	if got.IPAddress != "" || got.UserAgent != "" || got.Metadata != nil {
+		t.Fatalf("own event kept personal data: %+v", got)
+	}
+	if got.UserID == nil || *got.UserID != user.ID {
+		t.Fatalf("own event lost its user: %+v", got)
+	}
+
+	// The admin's request details are theirs, not the purged user's
+	got = load(byAdmin.ID)
+	if got.IPAddress != byAdmin.IPAddress || got.UserAgent != byAdmin.UserAgent {
+		t.Fatalf("admin event lost the admin's request details: %+v", got)
+	}
+	if got.Metadata != nil {
+		t.Fatalf("admin event kept metadata about the user: %+v", got)
+	}
+}
+
+func TestRestoreAccount(t *testing.T) {
+	testutil.DB(t)
+	user := testutil.CreateUser(t, nil)
+
+	token, _, err := ScheduleAccountDeletion(user.ID)
+	if err != nil {
+		t.Fatalf("ScheduleAccountDeletion: %v", err)
+	}
+	if err := config.DB.First(&models.User{}, "id = ?", user.ID).Error; err == nil {
+		t.Fatal("deleted account is still active")
+	}
+
+	restored, err := RestoreAccount(token)
+	if err != nil {
+		t.Fatalf("RestoreAccount: %v", err)
+	}
+	if restored.ID != user.ID {
+		t.Fatalf("restored %s, want %s", restored.ID, user.ID)
+	}
+	if err := config.DB.First(&models.User{}, "id = ?", user.ID).Error; err != nil {
+		t.Fatalf("restored account not active: %v", err)
+	}
+
+	if _, err := RestoreAccount(token); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reused token: err = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestPendingDeletion(t *testing.T) {
+	testutil.DB(t)
+	user := testutil.CreateUser(t, nil)
+
+	if _, pending := PendingDeletion(user.Email); pending {
+		t.Fatal("active account reported as pending deletion")
+	}
+
+	before := time.Now()
+	if _, _, err := ScheduleAccountDeletion(user.ID); err != nil {
+		t.Fatalf("ScheduleAccountDeletion: %v", err)
+	}
+	purgeAt, pending := PendingDeletion(user.Email)
+	if !pending {
+		t.Fatal("deleted account not reported as pending deletion")
+	}
+	if want := before.Add(AccountDeletionGrace); purgeAt.Before(want.Add(-time.Minute)) || purgeAt.After(want.Add(time.Minute)) {
+		t.Fatalf("purgeAt = %v, want about %v", purgeAt, want)
+	}
+
+	if _, pending := PendingDeletion("nobody@example.com"); pending {
+		t.Fatal("unknown email reported as pending deletion")
+	}
+}
+
+func TestClaimUnverifiedAccount(t *testing.T) {
+	testutil.DB(t)
+	password := "squatter-hash"
+	user := testutil.CreateUser(t, func(u *models.User) {
+		u.EmailVerified = false
+		u.Password = &password
+	})
+	testutil.CreateSession(t, user.ID)
+	if _, _, err := CreatePersonalAccessToken(user.ID, "ci", models.Scopes, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.OAuthAccount{
+		UserID:      user.ID,
+		Provider:    models.OAuthProvider("microsoft"),
+		ProviderUID: "squatter",
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	claimed, err := ClaimUnverifiedAccount(user.ID)
+	if err != nil {
+		t.Fatalf("ClaimUnverifiedAccount: %v", err)
+	}
+	if !claimed {
+		t.Fatal("unverified account was not claimed")
+	}
+
+	var got models.User
+	if err := config.DB.First(&got, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !got.EmailVerified || got.Password != nil {
+		t.Fatalf("user = %+v, want verified with no password", got)
+	}
+	for _, model := range []any{&models.Session{}, &models.PersonalAccessToken{}, &models.OAuthAccount{}} {
+		var count int64
+		config.DB.Unscoped().Model(model).Where("user_id = ?", user.ID).Count(&count)
+		if count != 0 {
+			t.Fatalf("%T: %d rows left, want 0", model, count)
+		}
+	}
+
+	// A verified account is never claimed again
+	if claimed, err := ClaimUnverifiedAccount(user.ID); err != nil || claimed {
+		t.Fatalf("second claim = %v, %v; want false, nil", claimed, err)
+	}
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"log"
+	"strings"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RecordAudit writes an audit event for the account userID, taking the IP,
+// user agent and acting user from the request. uuid.Nil means the event is
+// not tied to a known account, e.g. a login attempt for an unknown email.
+// Failures are logged rather than returned so auditing never blocks a request.
+func RecordAudit(c *gin.Context, action models.AuditAction, userID uuid.UUID, metadata map[string]any) {
+	event := models.AuditEvent{
+		UserID:    nilIfZero(userID),
+		Action:    action,
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 512),
+		Metadata:  metadata,
+	}
+
+	// Record who acted when it isn't the account owner, e.g. an admin. While
+	// impersonating, that is the admin behind the session.
+	if impersonatorAny, ok := c.Get("impersonator_id"); ok {
+		if impersonator, ok := impersonatorAny.(uuid.UUID); ok {
+			event.ActorID = &impersonator
+		}
+	} else if actorAny, ok := c.Get("user"); ok {
+		if actor, ok := actorAny.(models.User); ok && actor.ID != userID {
+			event.ActorID = &actor.ID
+		}
+	}
+
+	WriteAuditEvent(&event)
+}
+
+// WriteAuditEvent stores a fully built event, for callers outside a request
+func WriteAuditEvent(event *models.AuditEvent) {
+	if err := config.DB.Create(event).Error; err != nil {
+		log.Printf("⚠️ failed to record audit event %s: %v", event.Action, err)
+	}
+}
+
+func nilIfZero(id uuid.UUID) *uuid.UUID {
+	if id == uuid.Nil {
+		return nil
+	}
+	return &id
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
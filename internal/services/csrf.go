@@ -0,0 +1,67 @@
+package services
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	CSRFCookieName = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CSRFToken returns the browser's current CSRF token, issuing and setting a
+// new cookie if it has none. The frontend runs on another origin and cannot
+// read our cookies, so the token is also handed to it in the response body.
+func CSRFToken(c *gin.Context) (string, error) {
+	if token, err := c.Cookie(CSRFCookieName); err == nil && len(token) == 43 {
+		return token, nil
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		CSRFCookieName,
+		token,
+		0, // browser session
+		"/",
+		"",
+		false, // true in production (HTTPS)
+		true,  // HttpOnly
+	)
+	return token, nil
+}
+
+// CSRFTokenMatches compares the header token with the cookie, in constant time
+func CSRFTokenMatches(header, cookie string) bool {
+	return header != "" && constantTimeEqual(header, cookie)
+}
+
+// TrustedOrigin reports whether origin (scheme://host[:port]) is the
+// frontend or the API itself
+func TrustedOrigin(origin string) bool {
+	for _, trusted := range []string{FrontendURL(), BaseURL()} {
+		if sameOrigin(origin, trusted) {
+			return true
+		}
+	}
+	return false
+}
+
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host
+}
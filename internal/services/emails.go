@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"build-in-public/internal/models"
+)
+
+// SendVerificationEmail mails the user a link confirming their email address
+func SendVerificationEmail(ctx context.Context, user models.User, token string) error {
+	link := BaseURL() + "/auth/verify-email/confirm?token=" + url.QueryEscape(token)
+
+	return Mail.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in 24 hours. If you did not create an account, you can ignore this email.\n",
+			user.FirstName, link,
+		),
+	})
+}
+
+// SendPasswordResetEmail mails the user a link to choose a new password
+func SendPasswordResetEmail(ctx context.Context, user models.User, token string) error {
+	link := FrontendURL() + "/reset-password?token=" + url.QueryEscape(token)
+
+	return Mail.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to reset the password for your account. Choose a new password here:\n\n%s\n\nThe link expires in 1 hour and can only be used once. If this wasn't you, you can ignore this email.\n",
+			user.FirstName, link,
+		),
+	})
+}
+
+// MagicLinkURL is the frontend page that confirms a sign-in link. Opening it
+// spends nothing; the page posts the token to /auth/magic-link/consume.
+func MagicLinkURL(token string) string {
+	return FrontendURL() + "/magic-link?token=" + url.QueryEscape(token)
+}
+
+// SendMagicLinkEmail mails the user a link that signs them in without a password
+func SendMagicLinkEmail(ctx context.Context, user models.User, token string) error {
+	link := MagicLinkURL(token)
+
+	return Mail.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Your sign-in link",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to sign in:\n\n%s\n\nThe link expires in 15 minutes and can only be used once. If you did not ask to sign in, you can ignore this email.\n",
+			user.FirstName, link,
+		),
+	})
+}
+
+// SendAccountDeletionEmail confirms a deletion request and mails a link that
+// restores the account until it is purged
+func SendAccountDeletionEmail(ctx context.Context, user models.User, token string, purgeAt time.Time) error {
+	link := FrontendURL() + "/restore-account?token=" + url.QueryEscape(token)
+
+	return Mail.Send(ctx, Message{
+		To:      user.Email,
+		Subject: "Your account is scheduled for deletion",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nYour account has been deactivated and will be permanently deleted on %s. Until then you can restore it here:\n\n%s\n\nIf you did not ask for this, restore your account and change your password.\n",
+			user.FirstName, purgeAt.UTC().Format("2 January 2006"), link,
+		),
+	})
+}
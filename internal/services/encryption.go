@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm/schema"
+)
+
+// Encrypted values look like "enc:v2:<key id>:<wrapped data key>:<ciphertext>".
+// Each value gets its own random data key, sealed with AES-GCM; the data key
+// is in turn sealed with the key-encryption key named by the key id. The
+// ciphertext is bound to the table, column and row it is stored in, so a value
+// copied elsewhere in the database no longer decrypts.
+//
+// "enc:v1:" values predate that binding. Like plaintext written before
+// encryption was enabled, they are only read by `make rotate-keys`, which
+// rewrites them in the current format.
+const (
+	encryptedPrefix       = "enc:v2:"
+	legacyEncryptedPrefix = "enc:v1:"
+)
+
+var (
+	ErrUnknownEncryptionKey = errors.New("unknown encryption key")
+	ErrUnencryptedValue     = errors.New("value is not in the current encryption format, run make rotate-keys")
+)
+
+type keyring struct {
+	activeID string
+	keys     map[string]cipher.AEAD
+}
+
+var (
+	keyringMu   sync.RWMutex
+	tokenKeys   *keyring
+	warnedPlain sync.Once
+)
+
+func init() {
+	schema.RegisterSerializer("encrypted", encryptedSerializer{})
+}
+
+// InitEncryption loads the key-encryption keys used for secrets at rest.
+// TOKEN_ENCRYPTION_KEYS is a comma separated list of "<id>:<base64 32 byte key>"
+// and TOKEN_ENCRYPTION_ACTIVE_KEY names the one used for new writes. Retired
+// keys stay in the list until `make rotate-keys` has re-encrypted every row.
+func InitEncryption() {
+	raw := os.Getenv("TOKEN_ENCRYPTION_KEYS")
+	if raw == "" {
+		log.Println("⚠️ TOKEN_ENCRYPTION_KEYS not set, OAuth tokens will be stored unencrypted")
+		return
+	}
+
+	ring, err := parseKeyring(raw, os.Getenv("TOKEN_ENCRYPTION_ACTIVE_KEY"))
+	if err != nil {
+		log.Fatal("❌ Invalid token encryption keys:", err)
+	}
+
+	keyringMu.Lock()
+	tokenKeys = ring
+	keyringMu.Unlock()
+}
+
+// ActiveEncryptionKeyID returns the key id new values are encrypted with
+func ActiveEncryptionKeyID() string {
+	keyringMu.RLock()
+	defer keyringMu.RUnlock()
+	if tokenKeys == nil {
+		return ""
+	}
+	return tokenKeys.activeID
+}
+
+// EncryptedWithPrefix is the stored prefix of values sealed under the given key id
+func EncryptedWithPrefix(keyID string) string {
+	return encryptedPrefix + keyID + ":"
+}
+
+func parseKeyring(raw, activeID string) (*keyring, error) {
+	ring := &keyring{keys: map[string]cipher.AEAD{}}
+
+	for _, entry := range strings.Split(raw, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("malformed key entry %q", entry)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes of base64", id)
+		}
+
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		ring.keys[id] = aead
+
+		if activeID == "" {
+			activeID = id
+		}
+	}
+
+	if _, ok := ring.keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the key list", activeID)
+	}
+	ring.activeID = activeID
+	return ring, nil
+}
+
+// EncryptionContext names where a value is stored, e.g. "oauth_accounts.access_token:<id>"
+func EncryptionContext(table, column string, rowID any) string {
+	return fmt.Sprintf("%s.%s:%v", table, column, rowID)
+}
+
+// EncryptString seals plaintext under the active key, bound to the storage
+// context from EncryptionContext. Without configured keys the value is
+// returned unchanged.
+func EncryptString(plaintext, storage string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+
+	keyringMu.RLock()
+	ring := tokenKeys
+	keyringMu.RUnlock()
+
+	if ring == nil {
+		warnedPlain.Do(func() {
+			log.Println("⚠️ Writing secret without encryption, configure TOKEN_ENCRYPTION_KEYS")
+		})
+		return plaintext, nil
+	}
+	if storage == "" {
+		return "", errors.New("encryption context is required")
+	}
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", err
+	}
+
+	kek := ring.keys[ring.activeID]
+	wrappedKey, err := seal(kek, dataKey, []byte(ring.activeID))
+	if err != nil {
+		return "", err
+	}
+
+	dek, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(dek, []byte(plaintext), envelopeAAD(ring.activeID, storage))
+	if err != nil {
+		return "", err
+	}
+
+	return encryptedPrefix + ring.activeID + ":" +
+		base64.RawStdEncoding.EncodeToString(wrappedKey) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecryptString opens a value produced by EncryptString for the same storage
+// context. Once keys are configured, anything else is refused, including
+// plaintext: a row that skipped encryption is treated as tampered with.
+func DecryptString(stored, storage string) (string, error) {
+	if stored == "" {
+		return "", nil
+	}
+
+	keyringMu.RLock()
+	ring := tokenKeys
+	keyringMu.RUnlock()
+
+	if ring == nil {
+		// Encryption is disabled, so values were written in the clear
+		if strings.HasPrefix(stored, encryptedPrefix) || strings.HasPrefix(stored, legacyEncryptedPrefix) {
+			return "", ErrUnknownEncryptionKey
+		}
+		return stored, nil
+	}
+
+	if !strings.HasPrefix(stored, encryptedPrefix) {
+		return "", ErrUnencryptedValue
+	}
+	return openEnvelope(ring, strings.TrimPrefix(stored, encryptedPrefix), storage)
+}
+
+// ReencryptString rewrites a stored value under the active key, bound to
+// storage. Unlike DecryptString it also accepts plaintext and "enc:v1:"
+// values, so `make rotate-keys` can migrate them.
+func ReencryptString(stored, storage string) (string, error) {
+	keyringMu.RLock()
+	ring := tokenKeys
+	keyringMu.RUnlock()
+
+	if ring == nil {
+		return "", errors.New("TOKEN_ENCRYPTION_KEYS must be set to re-encrypt values")
+	}
+
+	var plaintext string
+	var err error
+	switch {
+	case stored == "":
+		return "", nil
+	case strings.HasPrefix(stored, encryptedPrefix):
+		plaintext, err = openEnvelope(ring, strings.TrimPrefix(stored, encryptedPrefix), storage)
+	case strings.HasPrefix(stored, legacyEncryptedPrefix):
+		plaintext, err = openEnvelope(ring, strings.TrimPrefix(stored, legacyEncryptedPrefix), "")
+	default:
+		plaintext = stored
+	}
+	if err != nil {
+		return "", err
+	}
+	return EncryptString(plaintext, storage)
+}
+
+// openEnvelope opens "<key id>:<wrapped data key>:<ciphertext>". An empty
+// storage context opens legacy values, which were bound to the key id alone.
+func openEnvelope(ring *keyring, envelope, storage string) (string, error) {
+	parts := strings.Split(envelope, ":")
+	if len(parts) != 3 {
+		return "", errors.New("malformed encrypted value")
+	}
+	keyID := parts[0]
+
+	kek, ok := ring.keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownEncryptionKey, keyID)
+	}
+
+	wrappedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+	ciphertext, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", errors.New("malformed encrypted value")
+	}
+
+	dataKey, err := open(kek, wrappedKey, []byte(keyID))
+	if err != nil {
+		return "", err
+	}
+	dek, err := newGCM(dataKey)
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := open(dek, ciphertext, envelopeAAD(keyID, storage))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// envelopeAAD is the additional data the ciphertext is sealed with
+func envelopeAAD(keyID, storage string) []byte {
+	if storage == "" {
+		return []byte(keyID)
+	}
+	return []byte(keyID + "|" + storage)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal returns nonce || ciphertext
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("malformed encrypted value")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, errors.New("failed to decrypt value")
+	}
+	return plaintext, nil
+}
+
+// encryptedSerializer transparently encrypts string columns tagged with
+// `gorm:"serializer:encrypted"`, binding each value to its table, column and
+// primary key. The row ID must therefore be known before the value is written
+// (see OAuthAccount.BeforeCreate) and selected whenever the column is.
+type encryptedSerializer struct{}
+
+func (encryptedSerializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue any) error {
+	var stored string
+	switch v := dbValue.(type) {
+	case nil:
+	case string:
+		stored = v
+	case []byte:
+		stored = string(v)
+	default:
+		return fmt.Errorf("unsupported encrypted column type %T", dbValue)
+	}
+	if stored == "" {
+		return field.Set(ctx, dst, "")
+	}
+
+	storage, err := storageContext(ctx, field, dst)
+	if err != nil {
+		return err
+	}
+	plaintext, err := DecryptString(stored, storage)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt %s: %w", field.Name, err)
+	}
+	return field.Set(ctx, dst, plaintext)
+}
+
+func (encryptedSerializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue any) (any, error) {
+	plaintext, ok := fieldValue.(string)
+	if !ok {
+		return nil, fmt.Errorf("encrypted field %s must be a string", field.Name)
+	}
+	if plaintext == "" {
+		return "", nil
+	}
+
+	storage, err := storageContext(ctx, field, dst)
+	if err != nil {
+		return nil, err
+	}
+	return EncryptString(plaintext, storage)
+}
+
+// storageContext is the EncryptionContext of the field in the row held by dst
+func storageContext(ctx context.Context, field *schema.Field, dst reflect.Value) (string, error) {
+	primaryKey := field.Schema.PrioritizedPrimaryField
+	if primaryKey == nil {
+		return "", fmt.Errorf("encrypted field %s needs a table with a primary key", field.Name)
+	}
+	id, zero := primaryKey.ValueOf(ctx, dst)
+	if zero {
+		return "", fmt.Errorf("encrypted field %s needs its row id", field.Name)
+	}
+	return EncryptionContext(field.Schema.Table, field.DBName, id), nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func useTestEncryptionKey(t *testing.T) {
+	t.Helper()
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := parseKeyring("test:"+base64.StdEncoding.EncodeToString(key), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyringMu.Lock()
+	tokenKeys = ring
+	keyringMu.Unlock()
+	t.Cleanup(func() {
+		keyringMu.Lock()
+		tokenKeys = nil
+		keyringMu.Unlock()
+	})
+}
+
+func TestEncryptStringIsBoundToStorage(t *testing.T) {
+	useTestEncryptionKey(t)
+	storage := EncryptionContext("oauth_accounts", "access_token", "row-1")
+
+	stored, err := EncryptString("secret", storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := DecryptString(stored, storage); err != nil || got != "secret" {
+		t.Fatalf("DecryptString = %q, %v; want secret", got, err)
+	}
+
+	for _, other := range []string{
+		EncryptionContext("oauth_accounts", "access_token", "row-2"),
+		EncryptionContext("oauth_accounts", "refresh_token", "row-1"),
+		EncryptionContext("other_table", "access_token", "row-1"),
+	} {
+		if _, err := DecryptString(stored, other); err == nil {
+			t.Errorf("value decrypted under %s", other)
+		}
+	}
+}
+
+func TestDecryptStringRefusesPlaintext(t *testing.T) {
+	useTestEncryptionKey(t)
+
+	_, err := DecryptString("ya29.plain-token", EncryptionContext("oauth_accounts", "access_token", "row-1"))
+	if !errors.Is(err, ErrUnencryptedValue) {
+		t.Fatalf("err = %v, want ErrUnencryptedValue", err)
+	}
+}
+
+func TestReencryptStringMigratesLegacyValues(t *testing.T) {
+	useTestEncryptionKey(t)
+	storage := EncryptionContext("oauth_accounts", "access_token", "row-1")
+
+	// A value in the "enc:v1:" format, bound to the key id alone
+	keyringMu.RLock()
+	kek := tokenKeys.keys["test"]
+	keyringMu.RUnlock()
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+	wrapped, err := seal(kek, dataKey, []byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dek, _ := newGCM(dataKey)
+	ciphertext, err := seal(dek, []byte("legacy"), []byte("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyEncryptedPrefix + "test:" +
+		base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(ciphertext)
+
+	if _, err := DecryptString(legacy, storage); !errors.Is(err, ErrUnencryptedValue) {
+		t.Fatalf("legacy value read directly: err = %v, want ErrUnencryptedValue", err)
+	}
+
+	for stored, want := range map[string]string{legacy: "legacy", "plain": "plain"} {
+		migrated, err := ReencryptString(stored, storage)
+		if err != nil {
+			t.Fatalf("ReencryptString(%q): %v", stored, err)
+		}
+		if got, err := DecryptString(migrated, storage); err != nil || got != want {
+			t.Fatalf("migrated value = %q, %v; want %q", got, err, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ImpersonationHeader is set on every response to an impersonated request,
+// carrying the ID of the admin behind it
+const (
+	ImpersonationHeader     = "X-Impersonated-By"
+	defaultImpersonationTTL = 15 * time.Minute
+)
+
+var (
+	ErrCannotImpersonate = errors.New("this account cannot be impersonated")
+	ErrNotImpersonating  = errors.New("session is not impersonating a user")
+)
+
+// ImpersonationTTL caps how long an impersonation session lasts; it is never extended
+var ImpersonationTTL = defaultImpersonationTTL
+
+// InitImpersonation reads the impersonation session lifetime
+func InitImpersonation() {
+	ImpersonationTTL = durationFromEnv("IMPERSONATION_TTL", defaultImpersonationTTL)
+}
+
+// StartImpersonation swaps the admin's session cookie for a short-lived
+// session of target. The admin's own session is left intact so
+// StopImpersonation can switch back to it. Staff accounts and suspended users
+// cannot be impersonated.
+func StartImpersonation(c *gin.Context, admin models.User, adminSession models.Session, target models.User) (*models.Session, error) {
+	if admin.ID == target.ID {
+		return nil, ErrCannotModifySelf
+	}
+	if target.Role != models.RoleUser || target.SuspendedAt != nil {
+		return nil, ErrCannotImpersonate
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(ImpersonationTTL)
+	if !adminSession.AbsoluteExpiresAt.IsZero() && adminSession.AbsoluteExpiresAt.Before(expiresAt) {
+		expiresAt = adminSession.AbsoluteExpiresAt
+	}
+
+	userAgent := c.Request.UserAgent()
+	session := models.Session{
+		UserID:                target.ID,
+		IPAddress:             c.ClientIP(),
+		UserAgent:             userAgent,
+		DeviceLabel:           DeviceLabel(userAgent),
+		LastSeenAt:            now,
+		ExpiresAt:             expiresAt,
+		AbsoluteExpiresAt:     expiresAt,
+		ImpersonatorID:        &admin.ID,
+		ImpersonatorSessionID: &adminSession.ID,
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		return nil, err
+	}
+
+	SetSessionCookie(c, &session)
+	return &session, nil
+}
+
+// StopImpersonation ends an impersonation session and restores the admin's
+// own session cookie if that session is still valid. It returns the admin's ID.
+func StopImpersonation(c *gin.Context, session models.Session) (uuid.UUID, error) {
+	if session.ImpersonatorID == nil {
+		return uuid.Nil, ErrNotImpersonating
+	}
+
+	if err := config.DB.Delete(&models.Session{}, "id = ?", session.ID).Error; err != nil {
+		return uuid.Nil, err
+	}
+
+	var parent models.Session
+	if session.ImpersonatorSessionID != nil &&
+		config.DB.First(&parent, "id = ?", *session.ImpersonatorSessionID).Error == nil &&
+		!SessionExpired(&parent, time.Now()) {
+		SetSessionCookie(c, &parent)
+	} else {
+		ClearSessionCookie(c)
+	}
+	return *session.ImpersonatorID, nil
+}
+
+// Why an impersonation session ended without the admin stopping it, as
+// recorded in the audit log
+const (
+	impersonationExpired       = "expired"
+	impersonationSessionEnded  = "admin_session_ended"
+	impersonationAccessRevoked = "admin_access_revoked"
+)
+
+// ImpersonationEndReason reports why an impersonation session has to end, or
+// "" while it may go on. Besides its own expiry, the admin behind it is checked
+// on every request: ending their session, whether by logout, revocation or
+// suspension, ends the impersonation with it, as does losing the permission
+// to impersonate.
+func ImpersonationEndReason(session *models.Session, now time.Time) string {
+	if SessionExpired(session, now) {
+		return impersonationExpired
+	}
+	if session.ImpersonatorID == nil || session.ImpersonatorSessionID == nil {
+		return impersonationSessionEnded
+	}
+
+	var parent models.Session
+	if err := config.DB.First(&parent, "id = ?", *session.ImpersonatorSessionID).Error; err != nil {
+		return impersonationSessionEnded
+	}
+	if parent.UserID != *session.ImpersonatorID || SessionExpired(&parent, now) {
+		return impersonationSessionEnded
+	}
+
+	var admin models.User
+	if err := config.DB.First(&admin, "id = ?", *session.ImpersonatorID).Error; err != nil {
+		return impersonationAccessRevoked
+	}
+	if admin.SuspendedAt != nil || !HasPermission(admin.Role, models.PermUsersImpersonate) {
+		return impersonationAccessRevoked
+	}
+	return ""
+}
+
+// EndImpersonation deletes an impersonation session that ended for reason and
+// records it in the audit log against the admin behind it. Only the request
+// that deletes the session records the event.
+func EndImpersonation(c *gin.Context, session *models.Session, reason string) error {
+	result := config.DB.Delete(&models.Session{}, "id = ?", session.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+
+	WriteAuditEvent(&models.AuditEvent{
+		UserID:    &session.UserID,
+		ActorID:   session.ImpersonatorID,
+		Action:    models.AuditImpersonationStopped,
+		IPAddress: c.ClientIP(),
+		UserAgent: truncate(c.Request.UserAgent(), 512),
+		Metadata:  map[string]any{"session_id": session.ID, "reason": reason},
+	})
+	return nil
+}
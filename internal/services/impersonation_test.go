@@ -0,0 +1,115 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+	"build-in-public/internal/testutil"
+
+	"github.com/gin-gonic/gin"
+)
+
+// impersonate creates an admin with a session impersonating a fresh user
+func impersonate(t *testing.T, now time.Time) (models.User, models.Session, models.Session) {
+	t.Helper()
+
+	admin := testutil.CreateUser(t, func(u *models.User) { u.Role = models.RoleAdmin })
+	adminSession := testutil.CreateSession(t, admin.ID)
+	target := testutil.CreateUser(t, nil)
+
+	session := models.Session{
+		UserID:                target.ID,
+		LastSeenAt:            now,
+		ExpiresAt:             now.Add(ImpersonationTTL),
+		AbsoluteExpiresAt:     now.Add(ImpersonationTTL),
+		ImpersonatorID:        &admin.ID,
+		ImpersonatorSessionID: &adminSession.ID,
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return admin, adminSession, session
+}
+
+func TestImpersonationEndReason(t *testing.T) {
+	testutil.DB(t)
+	SeedRoles()
+	now := time.Now()
+
+	tests := []struct {
+		name string
+		edit func(admin models.User, adminSession models.Session)
+		at   time.Time
+		want string
+	}{
+		{name: "valid", at: now},
+		{name: "expired", at: now.Add(ImpersonationTTL + time.Second), want: impersonationExpired},
+		{
+			name: "admin signed out",
+			edit: func(_ models.User, adminSession models.Session) {
+				config.DB.Delete(&adminSession)
+			},
+			at:   now,
+			want: impersonationSessionEnded,
+		},
+		{
+			name: "admin demoted",
+			edit: func(admin models.User, _ models.Session) {
+				config.DB.Model(&admin).Update("role", models.RoleModerator)
+			},
+			at:   now,
+			want: impersonationAccessRevoked,
+		},
+		{
+			name: "admin suspended",
+			edit: func(admin models.User, _ models.Session) {
+				config.DB.Model(&admin).Update("suspended_at", now)
+			},
+			at:   now,
+			want: impersonationAccessRevoked,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, adminSession, session := impersonate(t, now)
+			if tt.edit != nil {
+				tt.edit(admin, adminSession)
+			}
+			if got := ImpersonationEndReason(&session, tt.at); got != tt.want {
+				t.Fatalf("reason = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEndImpersonationIsAuditedOnce(t *testing.T) {
+	testutil.DB(t)
+	admin, _, session := impersonate(t, time.Now())
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/users/me", nil)
+	for range 2 {
+		if err := EndImpersonation(c, &session, impersonationExpired); err != nil {
+			t.Fatalf("EndImpersonation: %v", err)
+		}
+	}
+
+	var events []models.AuditEvent
+	if err := config.DB.Where("action = ?", models.AuditImpersonationStopped).Find(&events).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 {
+		t.Fatalf("recorded %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.ActorID == nil || *event.ActorID != admin.ID || *event.UserID != session.UserID {
+		t.Fatalf("event = %+v, want actor %s and user %s", event, admin.ID, session.UserID)
+	}
+	if event.Metadata["reason"] != impersonationExpired {
+		t.Fatalf("reason = %v, want %s", event.Metadata["reason"], impersonationExpired)
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LockoutPolicy controls how repeated failed password logins lock an account.
+// After Threshold consecutive failures every further failure locks the account
+// for Base, doubling each time up to Max.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+var lockoutPolicy = LockoutPolicy{
+	Threshold: 5,
+	Base:      time.Minute,
+	Max:       time.Hour,
+}
+
+// InitLockoutPolicy reads LOGIN_LOCKOUT_THRESHOLD, LOGIN_LOCKOUT_BASE and
+// LOGIN_LOCKOUT_MAX, falling back to 5 attempts, 1m and 1h
+func InitLockoutPolicy() {
+	if raw := os.Getenv("LOGIN_LOCKOUT_THRESHOLD"); raw != "" {
+		threshold, err := strconv.Atoi(raw)
+		if err != nil || threshold < 1 {
+			log.Fatalf("❌ Invalid LOGIN_LOCKOUT_THRESHOLD %q", raw)
+		}
+		lockoutPolicy.Threshold = threshold
+	}
+	lockoutPolicy.Base = durationFromEnv("LOGIN_LOCKOUT_BASE", lockoutPolicy.Base)
+	lockoutPolicy.Max = durationFromEnv("LOGIN_LOCKOUT_MAX", lockoutPolicy.Max)
+}
+
+// lockoutFor returns how long the account is locked after the given number
+// of consecutive failures
+func (p LockoutPolicy) lockoutFor(failures int) time.Duration {
+	if failures < p.Threshold {
+		return 0
+	}
+
+	lockout := p.Base
+	for i := p.Threshold; i < failures && lockout < p.Max; i++ {
+		lockout *= 2
+	}
+	return min(lockout, p.Max)
+}
+
+// AccountLocked reports whether password logins are currently locked for the
+// user and for how much longer
+func AccountLocked(user *models.User, now time.Time) (bool, time.Duration) {
+	if user.LockedUntil == nil || !user.LockedUntil.After(now) {
+		return false, 0
+	}
+	return true, user.LockedUntil.Sub(now)
+}
+
+// RecordFailedLogin counts a failed password attempt and locks the account
+// once the policy threshold is reached. It returns the consecutive failure
+// count and the new lockout, if any.
+func RecordFailedLogin(userID uuid.UUID) (int, time.Duration, error) {
+	var user models.User
+	var lockout time.Duration
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "failed_login_count", "locked_until").
+			First(&user, "id = ?", userID).Error; err != nil {
+			return err
+		}
+
+		user.FailedLoginCount++
+		updates := map[string]any{"failed_login_count": user.FailedLoginCount}
+
+		lockout = lockoutPolicy.lockoutFor(user.FailedLoginCount)
+		if lockout > 0 {
+			lockedUntil := time.Now().Add(lockout)
+			updates["locked_until"] = lockedUntil
+		}
+
+		return tx.Model(&models.User{}).Where("id = ?", userID).Updates(updates).Error
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return user.FailedLoginCount, lockout, nil
+}
+
+// ResetFailedLogins clears the failure counter and any lockout
+func ResetFailedLogins(db *gorm.DB, userID uuid.UUID) error {
+	return db.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]any{"failed_login_count": 0, "locked_until": nil}).Error
+}
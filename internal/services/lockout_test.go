@@ -0,0 +1,65 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"build-in-public/internal/models"
+)
+
+func TestLockoutFor(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 5, Base: time.Minute, Max: 10 * time.Minute}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{4, 0},
+		{5, time.Minute},
+		{6, 2 * time.Minute},
+		{7, 4 * time.Minute},
+		{8, 8 * time.Minute},
+		// Doubling stops at Max
+		{9, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := policy.lockoutFor(tt.failures); got != tt.want {
+			t.Errorf("lockoutFor(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLockoutForBaseAboveMax(t *testing.T) {
+	policy := LockoutPolicy{Threshold: 1, Base: time.Hour, Max: 30 * time.Minute}
+	if got := policy.lockoutFor(1); got != 30*time.Minute {
+		t.Fatalf("lockoutFor(1) = %s, want the 30m cap", got)
+	}
+}
+
+func TestAccountLocked(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := now.Add(90 * time.Second)
+	earlier := now.Add(-time.Second)
+
+	tests := []struct {
+		name        string
+		lockedUntil *time.Time
+		locked      bool
+		remaining   time.Duration
+	}{
+		{"never locked", nil, false, 0},
+		{"lock expired", &earlier, false, 0},
+		{"lock expiring now", &now, false, 0},
+		{"locked", &later, true, 90 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			locked, remaining := AccountLocked(&models.User{LockedUntil: tt.lockedUntil}, now)
+			if locked != tt.locked || remaining != tt.remaining {
+				t.Fatalf("AccountLocked = %v, %s; want %v, %s", locked, remaining, tt.locked, tt.remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengeTTL    = 5 * time.Minute
+	maxMFAAttempts     = 5
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10
+)
+
+var (
+	ErrInvalidMFACode        = errors.New("invalid verification code")
+	ErrMFAChallengeExpired   = errors.New("mfa challenge expired")
+	ErrMFAAlreadyEnabled     = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled         = errors.New("two-factor authentication is not enabled")
+	ErrTOTPEnrolmentNotFound = errors.New("no pending authenticator enrolment")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// MFAEnabled reports whether the user has a confirmed second factor
+func MFAEnabled(userID uuid.UUID) (bool, error) {
+	var count int64
+	err := config.DB.Model(&models.TOTPCredential{}).
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// BeginTOTPEnrolment stores a new unconfirmed secret for the user, replacing any earlier pending one
+func BeginTOTPEnrolment(userID uuid.UUID) (string, error) {
+	enabled, err := MFAEnabled(userID)
+	if err != nil {
+		return "", err
+	}
+	if enabled {
+		return "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TOTPCredential{UserID: userID, Secret: secret}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// ConfirmTOTPEnrolment activates the pending secret once the user proves their
+// authenticator produces valid codes, and returns a fresh set of recovery codes.
+func ConfirmTOTPEnrolment(userID uuid.UUID, code string, now time.Time) ([]string, error) {
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var credential models.TOTPCredential
+		if err := tx.Where("user_id = ?", userID).First(&credential).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrTOTPEnrolmentNotFound
+			}
+			return err
+		}
+		if credential.ConfirmedAt != nil {
+			return ErrMFAAlreadyEnabled
+		}
+
+		counter, ok := ValidateTOTP(credential.Secret, code, now)
+		if !ok {
+			return ErrInvalidMFACode
+		}
+
+		if err := tx.Model(&credential).Updates(map[string]any{
+			"confirmed_at": now,
+			"last_counter": counter,
+		}).Error; err != nil {
+			return err
+		}
+
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP removes the user's authenticator and recovery codes
+func DisableTOTP(userID uuid.UUID) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.TOTPCredential{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// VerifyTOTP checks a code from the user's confirmed authenticator. Each code
+// is accepted at most once.
+func VerifyTOTP(userID uuid.UUID, code string, now time.Time) error {
+	var credential models.TOTPCredential
+	if err := config.DB.
+		Where("user_id = ? AND confirmed_at IS NOT NULL", userID).
+		First(&credential).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMFANotEnabled
+		}
+		return err
+	}
+
+	counter, ok := ValidateTOTP(credential.Secret, code, now)
+	if !ok || counter <= credential.LastCounter {
+		return ErrInvalidMFACode
+	}
+
+	result := config.DB.Model(&models.TOTPCredential{}).
+		Where("id = ? AND last_counter < ?", credential.ID, counter).
+		Update("last_counter", counter)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// RegenerateRecoveryCodes invalidates the user's recovery codes and issues a new set
+func RegenerateRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// UseRecoveryCode consumes one of the user's unused recovery codes
+func UseRecoveryCode(userID uuid.UUID, code string, now time.Time) error {
+	result := config.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+// RemainingRecoveryCodes counts the user's unused recovery codes
+func RemainingRecoveryCodes(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := config.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateMFAChallenge records that the user passed their first factor at now
+func CreateMFAChallenge(userID uuid.UUID, now time.Time) (*models.MFAChallenge, error) {
+	challenge := models.MFAChallenge{
+		UserID:    userID,
+		ExpiresAt: now.Add(mfaChallengeTTL),
+	}
+	if err := config.DB.Create(&challenge).Error; err != nil {
+		return nil, err
+	}
+	return &challenge, nil
+}
+
+// CompleteMFAChallenge verifies the second factor for a pending challenge,
+// accepting either a TOTP code or a recovery code. The challenge is consumed
+// on success and discarded after too many failed attempts. On ErrInvalidMFACode
+// the challenge's user is still returned, for the audit log.
+func CompleteMFAChallenge(challengeID uuid.UUID, code, recoveryCode string, now time.Time) (uuid.UUID, error) {
+	var challenge models.MFAChallenge
+	if err := config.DB.First(&challenge, "id = ?", challengeID).Error; err != nil {
+		return uuid.Nil, ErrMFAChallengeExpired
+	}
+
+	// Claim an attempt before checking the code, so concurrent guesses
+	// cannot all slip in under the limit
+	result := config.DB.Model(&models.MFAChallenge{}).
+		Where("id = ? AND attempts < ? AND expires_at > ?", challenge.ID, maxMFAAttempts, now).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return uuid.Nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		config.DB.Delete(&challenge)
+		return uuid.Nil, ErrMFAChallengeExpired
+	}
+
+	var err error
+	switch {
+	case code != "":
+		err = VerifyTOTP(challenge.UserID, code, now)
+	case recoveryCode != "":
+		err = UseRecoveryCode(challenge.UserID, recoveryCode, now)
+	default:
+		err = ErrInvalidMFACode
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrInvalidMFACode) || errors.Is(err, ErrMFANotEnabled) {
+			return challenge.UserID, ErrInvalidMFACode
+		}
+		return uuid.Nil, err
+	}
+
+	result = config.DB.Delete(&models.MFAChallenge{}, "id = ?", challenge.ID)
+	if result.Error != nil {
+		return uuid.Nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Another request completed the same challenge first
+		return uuid.Nil, ErrMFAChallengeExpired
+	}
+
+	return challenge.UserID, nil
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and stores a new set
+func replaceRecoveryCodes(tx *gorm.DB, userID uuid.UUID) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: HashToken(normalizeRecoveryCode(code)),
+		})
+	}
+
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns a code such as "k3m9q-x7t2p"
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 7)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(b))[:recoveryCodeLength]
+	return code[:recoveryCodeLength/2] + "-" + code[recoveryCodeLength/2:], nil
+}
+
+// normalizeRecoveryCode makes recovery codes case and separator insensitive
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
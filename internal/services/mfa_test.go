@@ -0,0 +1,118 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+	"build-in-public/internal/testutil"
+
+	"github.com/google/uuid"
+)
+
+// enrolTOTP gives the user a confirmed authenticator and returns its secret
+func enrolTOTP(t *testing.T, userID uuid.UUID, now time.Time) string {
+	t.Helper()
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := config.DB.Create(&models.TOTPCredential{
+		UserID:      userID,
+		Secret:      secret,
+		ConfirmedAt: &now,
+	}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return secret
+}
+
+func TestCompleteMFAChallenge(t *testing.T) {
+	testutil.DB(t)
+	now := time.Now()
+	user := testutil.CreateUser(t, nil)
+	secret := enrolTOTP(t, user.ID, now)
+
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := CreateMFAChallenge(user.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := CompleteMFAChallenge(challenge.ID, code, "", now)
+	if err != nil {
+		t.Fatalf("CompleteMFAChallenge: %v", err)
+	}
+	if got != user.ID {
+		t.Fatalf("user = %s, want %s", got, user.ID)
+	}
+
+	// A challenge is consumed by its first success
+	if _, err := CompleteMFAChallenge(challenge.ID, code, "", now); !errors.Is(err, ErrMFAChallengeExpired) {
+		t.Fatalf("reused challenge: err = %v, want ErrMFAChallengeExpired", err)
+	}
+}
+
+func TestCompleteMFAChallengeExpired(t *testing.T) {
+	testutil.DB(t)
+	issued := time.Now()
+	user := testutil.CreateUser(t, nil)
+	secret := enrolTOTP(t, user.ID, issued)
+
+	challenge, err := CreateMFAChallenge(user.ID, issued)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	late := issued.Add(mfaChallengeTTL + time.Second)
+	code, err := TOTPCode(secret, late)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompleteMFAChallenge(challenge.ID, code, "", late); !errors.Is(err, ErrMFAChallengeExpired) {
+		t.Fatalf("err = %v, want ErrMFAChallengeExpired", err)
+	}
+
+	var count int64
+	config.DB.Model(&models.MFAChallenge{}).Where("id = ?", challenge.ID).Count(&count)
+	if count != 0 {
+		t.Fatal("expired challenge was not discarded")
+	}
+}
+
+func TestCompleteMFAChallengeAttemptsExhausted(t *testing.T) {
+	testutil.DB(t)
+	now := time.Now()
+	user := testutil.CreateUser(t, nil)
+	secret := enrolTOTP(t, user.ID, now)
+
+	challenge, err := CreateMFAChallenge(user.ID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < maxMFAAttempts; i++ {
+		got, err := CompleteMFAChallenge(challenge.ID, "000000", "", now)
+		if !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidMFACode", i+1, err)
+		}
+		if got != user.ID {
+			t.Fatalf("attempt %d: user = %s, want %s for the audit log", i+1, got, user.ID)
+		}
+	}
+
+	// Even the right code is refused once the attempts are used up
+	code, err := TOTPCode(secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CompleteMFAChallenge(challenge.ID, code, "", now); !errors.Is(err, ErrMFAChallengeExpired) {
+		t.Fatalf("err = %v, want ErrMFAChallengeExpired", err)
+	}
+}
@@ -5,75 +5,176 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
+	"net/http"
 	"os"
+	"sync"
+
+	"build-in-public/internal/models"
 
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/github"
 	"golang.org/x/oauth2/google"
 	"golang.org/x/oauth2/linkedin"
+	"golang.org/x/oauth2/microsoft"
 )
 
-type OAuthConfig struct {
-	Google   *oauth2.Config
-	GitHub   *oauth2.Config
-	LinkedIn *oauth2.Config
+// Provider is an OAuth identity provider users can sign in with
+type Provider interface {
+	Name() models.OAuthProvider
+	AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string
+	Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error)
+	UserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error)
+	// TokenSource refreshes token through the provider once it expires
+	TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource
 }
 
 type OAuthUserInfo struct {
-	ID        string
-	Email     string
-	FirstName string
-	LastName  string
-	AvatarURL string
-	Provider  string
+	ID    string
+	Email string
+	// EmailVerified is true only when the provider asserts it checked Email
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+	AvatarURL     string
+	Provider      string
+}
+
+// oauth2Provider is a Provider backed by a plain oauth2.Config and a
+// provider specific profile endpoint
+type oauth2Provider struct {
+	name      models.OAuthProvider
+	config    *oauth2.Config
+	fetchUser func(client *http.Client) (*OAuthUserInfo, error)
+}
+
+func (p *oauth2Provider) Name() models.OAuthProvider {
+	return p.name
+}
+
+func (p *oauth2Provider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return p.config.AuthCodeURL(state, opts...)
+}
+
+func (p *oauth2Provider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.config.Exchange(ctx, code, opts...)
+}
+
+func (p *oauth2Provider) UserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
+	return p.fetchUser(p.config.Client(ctx, token))
+}
+
+func (p *oauth2Provider) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return p.config.TokenSource(ctx, token)
+}
+
+var (
+	providersMu sync.RWMutex
+	providers   = map[models.OAuthProvider]Provider{}
+)
+
+// RegisterProvider makes a provider available under /auth/:provider
+func RegisterProvider(p Provider) {
+	providersMu.Lock()
+	defer providersMu.Unlock()
+	providers[p.Name()] = p
 }
 
-var OAuth *OAuthConfig
+// GetProvider looks up a registered provider
+func GetProvider(name models.OAuthProvider) (Provider, bool) {
+	providersMu.RLock()
+	defer providersMu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
 
+// InitOAuth registers every built-in provider that has credentials configured
 func InitOAuth() {
-	baseURL := os.Getenv("BASE_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8080"
-	}
+	baseURL := BaseURL()
 
-	OAuth = &OAuthConfig{
-		Google: &oauth2.Config{
-			ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
-			ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
-			RedirectURL:  baseURL + "/auth/google/callback",
-			Scopes: []string{
-				"https://www.googleapis.com/auth/userinfo.email",
-				"https://www.googleapis.com/auth/userinfo.profile",
+	builtins := []*oauth2Provider{
+		{
+			name: models.OAuthGoogle,
+			config: &oauth2.Config{
+				ClientID:     os.Getenv("GOOGLE_CLIENT_ID"),
+				ClientSecret: os.Getenv("GOOGLE_CLIENT_SECRET"),
+				RedirectURL:  baseURL + "/auth/google/callback",
+				Scopes: []string{
+					"https://www.googleapis.com/auth/userinfo.email",
+					"https://www.googleapis.com/auth/userinfo.profile",
+				},
+				Endpoint: google.Endpoint,
 			},
-			Endpoint: google.Endpoint,
+			fetchUser: fetchGoogleUserInfo,
 		},
-		GitHub: &oauth2.Config{
-			ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
-			ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
-			RedirectURL:  baseURL + "/auth/github/callback",
-			Scopes: []string{
-				"user:email",
-				"read:user",
+		{
+			name: models.OAuthGithub,
+			config: &oauth2.Config{
+				ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
+				ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
+				RedirectURL:  baseURL + "/auth/github/callback",
+				Scopes: []string{
+					"user:email",
+					"read:user",
+				},
+				Endpoint: github.Endpoint,
 			},
-			Endpoint: github.Endpoint,
+			fetchUser: fetchGitHubUserInfo,
 		},
-		LinkedIn: &oauth2.Config{
-			ClientID:     os.Getenv("LINKEDIN_CLIENT_ID"),
-			ClientSecret: os.Getenv("LINKEDIN_CLIENT_SECRET"),
-			RedirectURL:  baseURL + "/auth/linkedin/callback",
-			Scopes: []string{
-				"openid",
-				"profile",
-				"email",
+		{
+			name: models.OAuthLinkedIn,
+			config: &oauth2.Config{
+				ClientID:     os.Getenv("LINKEDIN_CLIENT_ID"),
+				ClientSecret: os.Getenv("LINKEDIN_CLIENT_SECRET"),
+				RedirectURL:  baseURL + "/auth/linkedin/callback",
+				Scopes: []string{
+					"openid",
+					"profile",
+					"email",
+				},
+				Endpoint: linkedin.Endpoint,
 			},
-			Endpoint: linkedin.Endpoint,
+			fetchUser: fetchLinkedInUserInfo,
+		},
+		{
+			name: models.OAuthMicrosoft,
+			config: &oauth2.Config{
+				ClientID:     os.Getenv("MICROSOFT_CLIENT_ID"),
+				ClientSecret: os.Getenv("MICROSOFT_CLIENT_SECRET"),
+				RedirectURL:  baseURL + "/auth/microsoft/callback",
+				Scopes: []string{
+					"openid",
+					"profile",
+					"email",
+					"offline_access",
+					"User.Read",
+				},
+				Endpoint: microsoft.AzureADEndpoint(microsoftTenant()),
+			},
+			fetchUser: fetchMicrosoftUserInfo,
 		},
 	}
+
+	for _, p := range builtins {
+		if p.config.ClientID == "" {
+			log.Printf("⚠️ %s OAuth not configured, skipping", p.name)
+			continue
+		}
+		RegisterProvider(p)
+	}
 }
 
-// GetGoogleUserInfo fetches user information from Google
-func GetGoogleUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
-	client := OAuth.Google.Client(ctx, token)
+// microsoftTenant is the Entra ID tenant to sign in against. "common" accepts
+// both work/school and personal Microsoft accounts.
+func microsoftTenant() string {
+	if tenant := os.Getenv("MICROSOFT_TENANT_ID"); tenant != "" {
+		return tenant
+	}
+	return "common"
+}
+
+// fetchGoogleUserInfo fetches user information from Google
+func fetchGoogleUserInfo(client *http.Client) (*OAuthUserInfo, error) {
 	resp, err := client.Get("https://www.googleapis.com/oauth2/v2/userinfo")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user info: %w", err)
@@ -100,19 +201,18 @@ func GetGoogleUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo
 	}
 
 	return &OAuthUserInfo{
-		ID:        googleUser.ID,
-		Email:     googleUser.Email,
-		FirstName: googleUser.GivenName,
-		LastName:  googleUser.FamilyName,
-		AvatarURL: googleUser.Picture,
-		Provider:  "google",
+		ID:            googleUser.ID,
+		Email:         googleUser.Email,
+		EmailVerified: googleUser.VerifiedEmail,
+		FirstName:     googleUser.GivenName,
+		LastName:      googleUser.FamilyName,
+		AvatarURL:     googleUser.Picture,
+		Provider:      "google",
 	}, nil
 }
 
-// GetGitHubUserInfo fetches user information from GitHub
-func GetGitHubUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
-	client := OAuth.GitHub.Client(ctx, token)
-
+// fetchGitHubUserInfo fetches user information from GitHub
+func fetchGitHubUserInfo(client *http.Client) (*OAuthUserInfo, error) {
 	// Get user info
 	resp, err := client.Get("https://api.github.com/user")
 	if err != nil {
@@ -137,31 +237,33 @@ func GetGitHubUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo
 		return nil, fmt.Errorf("failed to unmarshal user info: %w", err)
 	}
 
-	// If email is not public, fetch from emails endpoint
-	email := githubUser.Email
-	if email == "" {
-		emailResp, err := client.Get("https://api.github.com/user/emails")
+	// The profile email carries no verification flag, so always consult the
+	// emails endpoint; it also covers users whose email is not public
+	email, verified := githubUser.Email, false
+	emailResp, err := client.Get("https://api.github.com/user/emails")
+	if err == nil {
+		defer emailResp.Body.Close()
+		emailData, err := io.ReadAll(emailResp.Body)
 		if err == nil {
-			defer emailResp.Body.Close()
-			emailData, err := io.ReadAll(emailResp.Body)
-			if err == nil {
-				var emails []struct {
-					Email      string `json:"email"`
-					Primary    bool   `json:"primary"`
-					Verified   bool   `json:"verified"`
-					Visibility string `json:"visibility"`
-				}
-				if json.Unmarshal(emailData, &emails) == nil {
-					for _, e := range emails {
-						if e.Primary && e.Verified {
-							email = e.Email
-							break
-						}
+			var emails []struct {
+				Email      string `json:"email"`
+				Primary    bool   `json:"primary"`
+				Verified   bool   `json:"verified"`
+				Visibility string `json:"visibility"`
+			}
+			if json.Unmarshal(emailData, &emails) == nil {
+				for _, e := range emails {
+					if email == "" && e.Primary && e.Verified {
+						email = e.Email
 					}
-					if email == "" && len(emails) > 0 && emails[0].Verified {
-						email = emails[0].Email
+					if e.Email == email {
+						verified = e.Verified
+						break
 					}
 				}
+				if email == "" && len(emails) > 0 && emails[0].Verified {
+					email, verified = emails[0].Email, true
+				}
 			}
 		}
 	}
@@ -180,19 +282,18 @@ func GetGitHubUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo
 	}
 
 	return &OAuthUserInfo{
-		ID:        fmt.Sprintf("%d", githubUser.ID),
-		Email:     email,
-		FirstName: firstName,
-		LastName:  lastName,
-		AvatarURL: githubUser.AvatarURL,
-		Provider:  "github",
+		ID:            fmt.Sprintf("%d", githubUser.ID),
+		Email:         email,
+		EmailVerified: verified,
+		FirstName:     firstName,
+		LastName:      lastName,
+		AvatarURL:     githubUser.AvatarURL,
+		Provider:      "github",
 	}, nil
 }
 
-// GetLinkedInUserInfo fetches user information from LinkedIn
-func GetLinkedInUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
-	client := OAuth.LinkedIn.Client(ctx, token)
-
+// fetchLinkedInUserInfo fetches user information from LinkedIn
+func fetchLinkedInUserInfo(client *http.Client) (*OAuthUserInfo, error) {
 	// Get user profile
 	resp, err := client.Get("https://api.linkedin.com/v2/userinfo")
 	if err != nil {
@@ -220,12 +321,65 @@ func GetLinkedInUserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserIn
 	}
 
 	return &OAuthUserInfo{
-		ID:        linkedinUser.Sub,
-		Email:     linkedinUser.Email,
-		FirstName: linkedinUser.GivenName,
-		LastName:  linkedinUser.FamilyName,
-		AvatarURL: linkedinUser.Picture,
-		Provider:  "linkedin",
+		ID:            linkedinUser.Sub,
+		Email:         linkedinUser.Email,
+		EmailVerified: linkedinUser.EmailVerified,
+		FirstName:     linkedinUser.GivenName,
+		LastName:      linkedinUser.FamilyName,
+		AvatarURL:     linkedinUser.Picture,
+		Provider:      "linkedin",
+	}, nil
+}
+
+// fetchMicrosoftUserInfo fetches user information from Microsoft Graph
+func fetchMicrosoftUserInfo(client *http.Client) (*OAuthUserInfo, error) {
+	resp, err := client.Get("https://graph.microsoft.com/v1.0/me")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var microsoftUser struct {
+		ID                string `json:"id"`
+		DisplayName       string `json:"displayName"`
+		GivenName         string `json:"givenName"`
+		Surname           string `json:"surname"`
+		Mail              string `json:"mail"`
+		UserPrincipalName string `json:"userPrincipalName"`
+	}
+
+	if err := json.Unmarshal(data, &microsoftUser); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user info: %w", err)
+	}
+
+	// Personal accounts often have no mail attribute; the UPN is their sign-in address.
+	// Graph never asserts either was verified (tenants can set any value), so
+	// EmailVerified stays false and the address is never used to auto-link.
+	email := microsoftUser.Mail
+	if email == "" {
+		email = microsoftUser.UserPrincipalName
+	}
+
+	firstName, lastName := microsoftUser.GivenName, microsoftUser.Surname
+	if firstName == "" && microsoftUser.DisplayName != "" {
+		names := splitName(microsoftUser.DisplayName)
+		firstName = names[0]
+		if len(names) > 1 {
+			lastName = names[1]
+		}
+	}
+
+	return &OAuthUserInfo{
+		ID:        microsoftUser.ID,
+		Email:     email,
+		FirstName: firstName,
+		LastName:  lastName,
+		Provider:  "microsoft",
 	}, nil
 }
 
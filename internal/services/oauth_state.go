@@ -0,0 +1,145 @@
+package services
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+var ErrInvalidOAuthState = errors.New("invalid or expired oauth state")
+
+// OAuthFlow is a freshly started authorization: the raw state goes to the
+// provider and the browser, everything else stays on the server
+type OAuthFlow struct {
+	State        string
+	CodeVerifier string
+	Nonce        string
+}
+
+// AuthCodeOptions are the PKCE and nonce parameters for the authorization URL
+func (f *OAuthFlow) AuthCodeOptions() []oauth2.AuthCodeOption {
+	return []oauth2.AuthCodeOption{
+		oauth2.S256ChallengeOption(f.CodeVerifier),
+		oauth2.SetAuthURLParam("nonce", f.Nonce),
+	}
+}
+
+// StartOAuthFlow stores a new state, PKCE verifier and nonce for the provider.
+// returnTo must already have passed SafeReturnTo.
+func StartOAuthFlow(provider models.OAuthProvider, returnTo string) (*OAuthFlow, error) {
+	return startOAuthFlow(provider, returnTo, nil)
+}
+
+// StartOAuthLinkFlow is StartOAuthFlow for a signed-in user attaching the
+// provider to their account; the callback links instead of logging in
+func StartOAuthLinkFlow(provider models.OAuthProvider, userID uuid.UUID, returnTo string) (*OAuthFlow, error) {
+	return startOAuthFlow(provider, returnTo, &userID)
+}
+
+func startOAuthFlow(provider models.OAuthProvider, returnTo string, linkUserID *uuid.UUID) (*OAuthFlow, error) {
+	state, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	flow := &OAuthFlow{
+		State:        state,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+	}
+
+	now := time.Now()
+	record := models.OAuthState{
+		StateHash:    HashToken(state),
+		Provider:     provider,
+		CodeVerifier: flow.CodeVerifier,
+		Nonce:        flow.Nonce,
+		ReturnTo:     returnTo,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    now.Add(oauthStateTTL),
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Abandoned flows are cleaned up opportunistically
+		if err := tx.Where("expires_at < ?", now).Delete(&models.OAuthState{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return flow, nil
+}
+
+// ConsumeOAuthState looks up and deletes the state for the provider. Each
+// state can be consumed once; expired or unknown states are rejected.
+func ConsumeOAuthState(provider models.OAuthProvider, state string) (*models.OAuthState, error) {
+	if state == "" {
+		return nil, ErrInvalidOAuthState
+	}
+
+	var record models.OAuthState
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ? AND provider = ?", HashToken(state), provider).
+			First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidOAuthState
+			}
+			return err
+		}
+
+		result := tx.Delete(&models.OAuthState{}, "id = ?", record.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrInvalidOAuthState
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if record.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidOAuthState
+	}
+	return &record, nil
+}
+
+// OAuthStateMatches compares the state returned by the provider with the one
+// stored in the browser cookie, in constant time
+func OAuthStateMatches(returned, stored string) bool {
+	return returned != "" && constantTimeEqual(returned, stored)
+}
+
+// SafeReturnTo accepts only frontend-relative paths, so a post-login redirect
+// can never send the user to another site
+func SafeReturnTo(returnTo string) string {
+	if returnTo == "" || !strings.HasPrefix(returnTo, "/") || strings.HasPrefix(returnTo, "//") ||
+		strings.Contains(returnTo, "\\") {
+		return ""
+	}
+
+	u, err := url.Parse(returnTo)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return ""
+	}
+	return returnTo
+}
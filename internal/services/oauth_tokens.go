@@ -0,0 +1,248 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrReauthRequired means the stored grant can no longer be refreshed and the
+// user has to sign in with the provider again
+var ErrReauthRequired = errors.New("oauth account requires reauthentication")
+
+const (
+	// refreshLeeway refreshes tokens slightly before they expire so a request
+	// started with a valid token does not fail halfway
+	refreshLeeway = 5 * time.Minute
+
+	// lastUsedInterval limits how often using an account is written back
+	lastUsedInterval = time.Hour
+)
+
+// dbTokenSource is an oauth2.TokenSource for a stored OAuthAccount. Refreshed
+// tokens are written back, and a rejected refresh flags the account.
+type dbTokenSource struct {
+	ctx       context.Context
+	provider  Provider
+	accountID uuid.UUID
+
+	mu      sync.Mutex
+	current *oauth2.Token
+}
+
+// OAuthTokenSource returns a token source for calling the provider's API on
+// behalf of the account's owner, and marks the account as in use
+func OAuthTokenSource(ctx context.Context, account *models.OAuthAccount) (oauth2.TokenSource, error) {
+	ts, err := newDBTokenSource(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if account.LastUsedAt == nil || now.Sub(*account.LastUsedAt) > lastUsedInterval {
+		if err := config.DB.Model(&models.OAuthAccount{}).
+			Where("id = ?", account.ID).
+			Update("last_used_at", now).Error; err != nil {
+			log.Printf("failed to record use of oauth account %s: %v", account.ID, err)
+		}
+		account.LastUsedAt = &now
+	}
+	return ts, nil
+}
+
+func newDBTokenSource(ctx context.Context, account *models.OAuthAccount) (*dbTokenSource, error) {
+	if account.ReauthRequired {
+		return nil, ErrReauthRequired
+	}
+
+	provider, ok := GetProvider(account.Provider)
+	if !ok {
+		return nil, fmt.Errorf("provider %s is not configured", account.Provider)
+	}
+
+	return &dbTokenSource{
+		ctx:       ctx,
+		provider:  provider,
+		accountID: account.ID,
+		current:   accountToken(account),
+	}, nil
+}
+
+// OAuthClient returns an HTTP client that authenticates as the account's owner
+func OAuthClient(ctx context.Context, account *models.OAuthAccount) (*http.Client, error) {
+	ts, err := OAuthTokenSource(ctx, account)
+	if err != nil {
+		return nil, err
+	}
+	return oauth2.NewClient(ctx, ts), nil
+}
+
+func (s *dbTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if usable(s.current) {
+		return s.current, nil
+	}
+
+	var refreshErr error
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Lock the row so concurrent refreshes don't both spend a
+		// single-use refresh token
+		var account models.OAuthAccount
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&account, "id = ?", s.accountID).Error; err != nil {
+			return err
+		}
+
+		if account.ReauthRequired {
+			refreshErr = ErrReauthRequired
+			return nil
+		}
+
+		stored := accountToken(&account)
+		if usable(stored) {
+			// Another request refreshed while we waited for the lock
+			s.current = stored
+			return nil
+		}
+
+		if stored.RefreshToken == "" {
+			refreshErr = ErrReauthRequired
+			return tx.Model(&account).Update("reauth_required", true).Error
+		}
+
+		// Expire the copy so the oauth2 package refreshes it rather than
+		// reusing a token inside our leeway window
+		stored.Expiry = time.Now().Add(-time.Second)
+		fresh, err := s.provider.TokenSource(s.ctx, stored).Token()
+		if err != nil {
+			if grantRejected(err) {
+				log.Printf("⚠️ %s refresh rejected for oauth account %s, reauth required", account.Provider, account.ID)
+				refreshErr = ErrReauthRequired
+				return tx.Model(&account).Update("reauth_required", true).Error
+			}
+			refreshErr = fmt.Errorf("failed to refresh %s token: %w", account.Provider, err)
+			return nil
+		}
+
+		account.AccessToken = fresh.AccessToken
+		account.RefreshToken = fresh.RefreshToken
+		account.ExpiresAt = nil
+		if !fresh.Expiry.IsZero() {
+			expiresAt := fresh.Expiry
+			account.ExpiresAt = &expiresAt
+		}
+		if err := tx.Model(&account).
+			Select("access_token", "refresh_token", "expires_at").
+			Updates(&account).Error; err != nil {
+			return err
+		}
+
+		s.current = fresh
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if refreshErr != nil {
+		return nil, refreshErr
+	}
+	return s.current, nil
+}
+
+func accountToken(account *models.OAuthAccount) *oauth2.Token {
+	token := &oauth2.Token{
+		AccessToken:  account.AccessToken,
+		RefreshToken: account.RefreshToken,
+		TokenType:    "Bearer",
+	}
+	if account.ExpiresAt != nil {
+		token.Expiry = *account.ExpiresAt
+	}
+	return token
+}
+
+// usable reports whether a token has an access token that won't expire
+// within refreshLeeway. Tokens without an expiry never expire.
+func usable(token *oauth2.Token) bool {
+	if token == nil || token.AccessToken == "" {
+		return false
+	}
+	return token.Expiry.IsZero() || time.Until(token.Expiry) > refreshLeeway
+}
+
+// grantRejected separates a revoked or expired grant from transient failures
+// such as timeouts or provider outages, which are worth retrying later
+func grantRejected(err error) bool {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return false
+	}
+	if retrieveErr.ErrorCode == "invalid_grant" {
+		return true
+	}
+	return retrieveErr.Response != nil &&
+		retrieveErr.Response.StatusCode >= 400 && retrieveErr.Response.StatusCode < 500
+}
+
+// StartOAuthTokenRefresher refreshes tokens that are about to expire in the
+// background, so grants that are revoked surface as reauth required before a
+// feature needs them. OAUTH_REFRESH_INTERVAL sets how often it runs.
+// Accounts unused for OAUTH_REFRESH_IDLE_AFTER are left alone: refreshing
+// them would keep grants alive that nobody needs, and they are still
+// refreshed on demand when next used.
+func StartOAuthTokenRefresher(ctx context.Context) {
+	interval := durationFromEnv("OAUTH_REFRESH_INTERVAL", 10*time.Minute)
+	idleAfter := durationFromEnv("OAUTH_REFRESH_IDLE_AFTER", 30*24*time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				refreshExpiringOAuthTokens(ctx, interval+refreshLeeway, idleAfter)
+			}
+		}
+	}()
+}
+
+func refreshExpiringOAuthTokens(ctx context.Context, within, idleAfter time.Duration) {
+	now := time.Now()
+	var accounts []models.OAuthAccount
+	err := config.DB.
+		Where("refresh_token <> '' AND reauth_required = ? AND expires_at < ?", false, now.Add(within)).
+		Where("last_used_at > ?", now.Add(-idleAfter)).
+		Find(&accounts).Error
+	if err != nil {
+		log.Println("⚠️ Failed to load expiring OAuth tokens:", err)
+		return
+	}
+
+	for i := range accounts {
+		// Not OAuthTokenSource, as refreshing does not count as use
+		ts, err := newDBTokenSource(ctx, &accounts[i])
+		if err != nil {
+			continue
+		}
+		if _, err := ts.Token(); err != nil && !errors.Is(err, ErrReauthRequired) {
+			log.Printf("⚠️ Background refresh of oauth account %s failed: %v", accounts[i].ID, err)
+		}
+	}
+}
@@ -0,0 +1,452 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"build-in-public/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+const (
+	oidcClockSkew       = time.Minute
+	jwksRefreshInterval = time.Minute
+)
+
+var ErrInvalidIDToken = errors.New("invalid id token")
+
+var oidcSlugPattern = regexp.MustCompile(`^[a-z0-9-]{1,50}$`)
+
+// reservedAuthSegments are the fixed routes under /auth, which a provider
+// slug would otherwise shadow or be shadowed by
+var reservedAuthSegments = []string{
+	"account", "csrf", "impersonation", "login", "logout", "magic-link",
+	"mfa", "passkey", "password", "signup", "token", "verify-email",
+}
+
+// OIDCProviderConfig describes an OpenID Connect issuer such as a college SSO
+type OIDCProviderConfig struct {
+	// Slug names the provider in /auth/:provider and must not clash with a built-in provider
+	Slug         string   `json:"slug"`
+	Issuer       string   `json:"issuer"`
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+	// Claims overrides which claim fills each OAuthUserInfo field, e.g. {"email": "upn"}
+	Claims map[string]string `json:"claims"`
+}
+
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	UserinfoEndpoint      string   `json:"userinfo_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// OIDCProvider is a Provider for any issuer that publishes an OpenID
+// discovery document. ID tokens are verified against the issuer's JWKS.
+type OIDCProvider struct {
+	cfg        OIDCProviderConfig
+	discovery  oidcDiscovery
+	oauth      *oauth2.Config
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	keysFetched time.Time
+}
+
+type oidcNonceKey struct{}
+
+// WithOIDCNonce attaches the nonce sent in the authorization request, so the
+// provider can check the ID token was minted for this login
+func WithOIDCNonce(ctx context.Context, nonce string) context.Context {
+	return context.WithValue(ctx, oidcNonceKey{}, nonce)
+}
+
+// InitOIDC registers the OpenID Connect providers listed in OIDC_PROVIDERS
+// (a JSON array) or the file named by OIDC_PROVIDERS_FILE
+func InitOIDC() {
+	data := []byte(os.Getenv("OIDC_PROVIDERS"))
+	if path := os.Getenv("OIDC_PROVIDERS_FILE"); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			log.Printf("⚠️ Failed to read OIDC providers: %v", err)
+			return
+		}
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return
+	}
+
+	var configs []OIDCProviderConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		log.Printf("⚠️ Failed to parse OIDC providers: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, cfg := range configs {
+		if _, exists := GetProvider(models.OAuthProvider(cfg.Slug)); exists {
+			log.Printf("⚠️ OIDC provider %q clashes with an existing provider, skipping", cfg.Slug)
+			continue
+		}
+
+		provider, err := NewOIDCProvider(ctx, cfg, http.DefaultClient)
+		if err != nil {
+			log.Printf("⚠️ OIDC provider %q unavailable: %v", cfg.Slug, err)
+			continue
+		}
+		RegisterProvider(provider)
+		log.Printf("✅ OIDC provider %q registered (%s)", cfg.Slug, cfg.Issuer)
+	}
+}
+
+// NewOIDCProvider runs discovery against the issuer and loads its signing keys
+func NewOIDCProvider(ctx context.Context, cfg OIDCProviderConfig, httpClient *http.Client) (*OIDCProvider, error) {
+	if cfg.Slug == "" || cfg.Issuer == "" || cfg.ClientID == "" {
+		return nil, errors.New("slug, issuer and client_id are required")
+	}
+	if err := checkProviderSlug(cfg.Slug); err != nil {
+		return nil, err
+	}
+	if err := checkIssuerURL(cfg.Issuer); err != nil {
+		return nil, err
+	}
+
+	wellKnown := strings.TrimSuffix(cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	var discovery oidcDiscovery
+	if err := getJSON(ctx, httpClient, wellKnown, &discovery); err != nil {
+		return nil, fmt.Errorf("discovery failed: %w", err)
+	}
+
+	// OpenID Connect Discovery 1.0 §4.3: the document must name the issuer we asked
+	if discovery.Issuer != cfg.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", discovery.Issuer, cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing required endpoints")
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	if !slices.Contains(scopes, "openid") {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	p := &OIDCProvider{
+		cfg:       cfg,
+		discovery: discovery,
+		oauth: &oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: cfg.ClientSecret,
+			RedirectURL:  BaseURL() + "/auth/" + cfg.Slug + "/callback",
+			Scopes:       scopes,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  discovery.AuthorizationEndpoint,
+				TokenURL: discovery.TokenEndpoint,
+			},
+		},
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *OIDCProvider) Name() models.OAuthProvider {
+	return models.OAuthProvider(p.cfg.Slug)
+}
+
+func (p *OIDCProvider) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) string {
+	return p.oauth.AuthCodeURL(state, opts...)
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code string, opts ...oauth2.AuthCodeOption) (*oauth2.Token, error) {
+	return p.oauth.Exchange(p.clientContext(ctx), code, opts...)
+}
+
+func (p *OIDCProvider) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return p.oauth.TokenSource(p.clientContext(ctx), token)
+}
+
+// UserInfo verifies the ID token returned with the access token and maps its
+// claims. The userinfo endpoint is only consulted for claims the ID token lacks.
+func (p *OIDCProvider) UserInfo(ctx context.Context, token *oauth2.Token) (*OAuthUserInfo, error) {
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, fmt.Errorf("%w: missing from token response", ErrInvalidIDToken)
+	}
+
+	nonce, _ := ctx.Value(oidcNonceKey{}).(string)
+	claims, err := p.VerifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.claim(claims, "email") == "" && p.discovery.UserinfoEndpoint != "" {
+		var extra map[string]any
+		client := p.oauth.Client(p.clientContext(ctx), token)
+		if err := getJSON(ctx, client, p.discovery.UserinfoEndpoint, &extra); err == nil {
+			// OpenID Connect Core §5.3.2: userinfo must describe the same subject
+			if sub, _ := extra["sub"].(string); sub == claims["sub"] {
+				for k, v := range extra {
+					if _, ok := claims[k]; !ok {
+						claims[k] = v
+					}
+				}
+			}
+		}
+	}
+
+	info := &OAuthUserInfo{
+		ID:            p.claim(claims, "id"),
+		Email:         p.claim(claims, "email"),
+		EmailVerified: p.claim(claims, "email_verified") == "true",
+		FirstName:     p.claim(claims, "first_name"),
+		LastName:      p.claim(claims, "last_name"),
+		AvatarURL:     p.claim(claims, "avatar_url"),
+		Provider:      p.cfg.Slug,
+	}
+	if info.FirstName == "" {
+		if name := p.claim(claims, "name"); name != "" {
+			names := splitName(name)
+			info.FirstName = names[0]
+			if len(names) > 1 {
+				info.LastName = names[1]
+			}
+		}
+	}
+	if info.ID == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	return info, nil
+}
+
+// VerifyIDToken checks the signature and standard claims of an ID token
+// (OpenID Connect Core §3.1.3.7) and returns its claims
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, raw, expectedNonce string) (map[string]any, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(p.signingAlgs()),
+		jwt.WithIssuer(p.cfg.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(oidcClockSkew),
+		jwt.WithTimeFunc(p.now),
+	)
+
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return p.signingKey(ctx, kid)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidIDToken, err)
+	}
+
+	// §3.1.3.7 (5): with several audiences, azp must name this client
+	if audiences, _ := claims.GetAudience(); len(audiences) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.cfg.ClientID {
+			return nil, fmt.Errorf("%w: authorized party mismatch", ErrInvalidIDToken)
+		}
+	}
+
+	if expectedNonce == "" {
+		return nil, fmt.Errorf("%w: no nonce to check against", ErrInvalidIDToken)
+	}
+	if nonce, _ := claims["nonce"].(string); !constantTimeEqual(nonce, expectedNonce) {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+// claim reads the string claim mapped to an OAuthUserInfo field
+func (p *OIDCProvider) claim(claims map[string]any, field string) string {
+	defaults := map[string]string{
+		"id":             "sub",
+		"email":          "email",
+		"email_verified": "email_verified",
+		"first_name":     "given_name",
+		"last_name":      "family_name",
+		"name":           "name",
+		"avatar_url":     "picture",
+	}
+
+	name := defaults[field]
+	if override, ok := p.cfg.Claims[field]; ok {
+		name = override
+	}
+
+	// Booleans such as email_verified are normalised to "true"/"false"
+	switch value := claims[name].(type) {
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	return ""
+}
+
+// signingAlgs lists the algorithms the issuer may sign ID tokens with. HMAC
+// is never accepted, as the client secret is not a signing key we trust.
+func (p *OIDCProvider) signingAlgs() []string {
+	if len(p.discovery.SigningAlgs) == 0 {
+		return []string{"RS256"}
+	}
+	return slices.DeleteFunc(slices.Clone(p.discovery.SigningAlgs), func(alg string) bool {
+		return alg == "none" || strings.HasPrefix(alg, "HS")
+	})
+}
+
+// signingKey finds the key for kid, refetching the JWKS once if the issuer has rotated keys
+func (p *OIDCProvider) signingKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	key, ok := p.lookupKey(kid)
+	stale := p.now().Sub(p.keysFetched) > jwksRefreshInterval
+	p.mu.Unlock()
+
+	if ok {
+		return key, nil
+	}
+	if !stale {
+		return nil, ErrKeyNotFound
+	}
+
+	if err := p.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if key, ok := p.lookupKey(kid); ok {
+		return key, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+// lookupKey must be called with mu held. A token without a kid is only
+// accepted when the issuer publishes exactly one key.
+func (p *OIDCProvider) lookupKey(kid string) (crypto.PublicKey, bool) {
+	if kid == "" {
+		if len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key, true
+			}
+		}
+		return nil, false
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *OIDCProvider) refreshKeys(ctx context.Context) error {
+	var set JSONWebKeySet
+	if err := getJSON(ctx, p.httpClient, p.discovery.JWKSURI, &set); err != nil {
+		return fmt.Errorf("failed to fetch jwks: %w", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	if len(keys) == 0 {
+		return errors.New("jwks contains no usable signing keys")
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.keysFetched = p.now()
+	p.mu.Unlock()
+	return nil
+}
+
+// clientContext makes the oauth2 package use our HTTP client
+func (p *OIDCProvider) clientContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, p.httpClient)
+}
+
+// checkProviderSlug keeps the slug usable as a single, unambiguous /auth path segment
+func checkProviderSlug(slug string) error {
+	if !oidcSlugPattern.MatchString(slug) {
+		return fmt.Errorf("slug %q must be 1-50 lowercase letters, digits or hyphens", slug)
+	}
+	if slices.Contains(reservedAuthSegments, slug) {
+		return fmt.Errorf("slug %q is reserved", slug)
+	}
+	return nil
+}
+
+// checkIssuerURL requires https, except for local stub issuers during development
+func checkIssuerURL(issuer string) error {
+	u, err := url.Parse(issuer)
+	if err != nil {
+		return fmt.Errorf("invalid issuer: %w", err)
+	}
+	if u.Scheme == "https" {
+		return nil
+	}
+	if u.Scheme == "http" && (u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1") {
+		return nil
+	}
+	return errors.New("issuer must use https")
+}
+
+func getJSON(ctx context.Context, client *http.Client, endpoint string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %d", endpoint, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	stubClientID = "stub-client"
+	stubKeyID    = "stub-key"
+	stubNonce    = "expected-nonce"
+)
+
+// stubIssuer serves a discovery document and JWKS for a single RSA key
+type stubIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+func newStubIssuer(t *testing.T) *stubIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &stubIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                s.server.URL,
+			AuthorizationEndpoint: s.server.URL + "/authorize",
+			TokenEndpoint:         s.server.URL + "/token",
+			JWKSURI:               s.server.URL + "/jwks",
+			SigningAlgs:           []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(JSONWebKeySet{Keys: []JSONWebKey{{
+			Kty: "RSA",
+			Kid: stubKeyID,
+			Use: "sig",
+			Alg: "RS256",
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	s.server = httptest.NewServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// provider runs discovery against the stub with the clock fixed at now
+func (s *stubIssuer) provider(t *testing.T, now time.Time) *OIDCProvider {
+	t.Helper()
+
+	p, err := NewOIDCProvider(context.Background(), OIDCProviderConfig{
+		Slug:     "stub",
+		Issuer:   s.server.URL,
+		ClientID: stubClientID,
+	}, s.server.Client())
+	if err != nil {
+		t.Fatalf("NewOIDCProvider: %v", err)
+	}
+	p.now = func() time.Time { return now }
+	return p
+}
+
+// claims returns a valid set of ID token claims, issued at now
+func (s *stubIssuer) claims(now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":   s.server.URL,
+		"sub":   "user-123",
+		"aud":   stubClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": stubNonce,
+		"email": "student@college.example",
+	}
+}
+
+func (s *stubIssuer) sign(t *testing.T, claims jwt.MapClaims, kid string, key *rsa.PrivateKey) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer := newStubIssuer(t)
+	now := time.Now()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		edit    func(jwt.MapClaims)
+		kid     string
+		key     *rsa.PrivateKey
+		nonce   string
+		wantErr error
+	}{
+		{name: "valid"},
+		{name: "bad signature", key: otherKey, wantErr: jwt.ErrTokenSignatureInvalid},
+		{name: "wrong audience", edit: func(c jwt.MapClaims) { c["aud"] = "someone-else" }, wantErr: jwt.ErrTokenInvalidAudience},
+		{name: "wrong issuer", edit: func(c jwt.MapClaims) { c["iss"] = "https://evil.example" }, wantErr: jwt.ErrTokenInvalidIssuer},
+		{name: "expired", edit: func(c jwt.MapClaims) { c["exp"] = now.Add(-2 * oidcClockSkew).Unix() }, wantErr: jwt.ErrTokenExpired},
+		{name: "nonce mismatch", nonce: "another-login", wantErr: ErrInvalidIDToken},
+		{name: "unknown kid", kid: "rotated-away", wantErr: ErrKeyNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := issuer.claims(now)
+			if tt.edit != nil {
+				tt.edit(claims)
+			}
+			kid := stubKeyID
+			if tt.kid != "" {
+				kid = tt.kid
+			}
+			key := issuer.key
+			if tt.key != nil {
+				key = tt.key
+			}
+			nonce := stubNonce
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			raw := issuer.sign(t, claims, kid, key)
+			got, err := issuer.provider(t, now).VerifyIDToken(context.Background(), raw, nonce)
+
+			if tt.wantErr == nil {
+				if err != nil {
+					t.Fatalf("VerifyIDToken: %v", err)
+				}
+				if got["sub"] != "user-123" {
+					t.Fatalf("sub = %v, want user-123", got["sub"])
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidIDToken) {
+				t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenRejectsHMAC(t *testing.T) {
+	issuer := newStubIssuer(t)
+	now := time.Now()
+
+	// Signed with the client secret, which an attacker may know
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.claims(now))
+	token.Header["kid"] = stubKeyID
+	raw, err := token.SignedString([]byte("client-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := issuer.provider(t, now).VerifyIDToken(context.Background(), raw, stubNonce); !errors.Is(err, ErrInvalidIDToken) {
+		t.Fatalf("err = %v, want ErrInvalidIDToken", err)
+	}
+}
+
+func TestCheckProviderSlug(t *testing.T) {
+	tests := []struct {
+		slug string
+		ok   bool
+	}{
+		{"iitb", true},
+		{"college-sso-2", true},
+		{"", false},
+		{"College", false},
+		{"a/b", false},
+		{"../token", false},
+		{"sso_main", false},
+		{"token", false},
+		{"magic-link", false},
+		{"verify-email", false},
+		{strings.Repeat("a", 51), false},
+	}
+	for _, tt := range tests {
+		if err := checkProviderSlug(tt.slug); (err == nil) != tt.ok {
+			t.Errorf("checkProviderSlug(%q) = %v, want ok %v", tt.slug, err, tt.ok)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PasswordAlgorithmBcrypt   = "bcrypt"
+	PasswordAlgorithmArgon2id = "argon2id"
+)
+
+var ErrUnknownPasswordHash = errors.New("unrecognised password hash format")
+
+// PasswordHasher produces and checks self-describing password hashes. The
+// parameters a hash was made with are encoded in it, so raising the cost only
+// affects new hashes and old ones keep verifying.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Verify(encoded, password string) (bool, error)
+	// Recognises reports whether encoded was produced by this algorithm
+	Recognises(encoded string) bool
+	// Outdated reports whether encoded was made with different parameters
+	Outdated(encoded string) bool
+}
+
+// BcryptHasher hashes with bcrypt at the given cost
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h BcryptHasher) Hash(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.Cost)
+	if err != nil {
+		return "", err
+	}
+	return string(hashed), nil
+}
+
+func (h BcryptHasher) Verify(encoded, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (h BcryptHasher) Recognises(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+func (h BcryptHasher) Outdated(encoded string) bool {
+	cost, err := bcrypt.Cost([]byte(encoded))
+	return err != nil || cost != h.Cost
+}
+
+// Argon2idHasher hashes with Argon2id, encoded in the PHC string format
+// "$argon2id$v=19$m=<KiB>,t=<iterations>,p=<threads>$<salt>$<key>"
+type Argon2idHasher struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  int
+	KeyLength   uint32
+}
+
+type argon2Params struct {
+	memory      uint32
+	iterations  uint32
+	parallelism uint8
+	salt        []byte
+	key         []byte
+}
+
+func (h Argon2idHasher) Hash(password string) (string, error) {
+	salt := make([]byte, h.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.Iterations, h.Memory, h.Parallelism, h.KeyLength)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, h.Memory, h.Iterations, h.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func (h Argon2idHasher) Verify(encoded, password string) (bool, error) {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	key := argon2.IDKey([]byte(password), params.salt, params.iterations, params.memory, params.parallelism, uint32(len(params.key)))
+	return subtle.ConstantTimeCompare(key, params.key) == 1, nil
+}
+
+func (h Argon2idHasher) Recognises(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func (h Argon2idHasher) Outdated(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+	return params.memory != h.Memory ||
+		params.iterations != h.Iterations ||
+		params.parallelism != h.Parallelism ||
+		len(params.salt) != h.SaltLength ||
+		uint32(len(params.key)) != h.KeyLength
+}
+
+func decodeArgon2id(encoded string) (*argon2Params, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, ErrUnknownPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, ErrUnknownPasswordHash
+	}
+
+	var params argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.memory, &params.iterations, &params.parallelism); err != nil {
+		return nil, ErrUnknownPasswordHash
+	}
+
+	var err error
+	if params.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return nil, ErrUnknownPasswordHash
+	}
+	if params.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(params.key) == 0 {
+		return nil, ErrUnknownPasswordHash
+	}
+	return &params, nil
+}
+
+var (
+	defaultBcrypt   = BcryptHasher{Cost: bcrypt.DefaultCost}
+	defaultArgon2id = Argon2idHasher{Memory: 64 * 1024, Iterations: 3, Parallelism: 2, SaltLength: 16, KeyLength: 32}
+)
+
+// passwordHasher makes new hashes; verifiers check stored ones of any supported
+// algorithm. Hashes the current hasher does not recognise, or made with other
+// parameters, are replaced on the user's next successful login.
+var (
+	passwordHasher PasswordHasher = defaultBcrypt
+	verifiers                     = []PasswordHasher{defaultBcrypt, defaultArgon2id}
+)
+
+// hashSlots bounds how many hashes are computed at once. Each Argon2id hash
+// takes Memory KiB, so a burst of logins from many IPs could otherwise exhaust
+// memory; requests beyond the bound wait for a slot instead.
+var hashSlots = make(chan struct{}, runtime.NumCPU())
+
+// withHashSlot runs f once a hashing slot is free
+func withHashSlot(f func()) {
+	hashSlots <- struct{}{}
+	defer func() { <-hashSlots }()
+	f()
+}
+
+// InitPasswordHashing selects the algorithm and cost for new password hashes
+// and how many may be computed at once (PASSWORD_HASH_CONCURRENCY, default
+// one per CPU)
+func InitPasswordHashing() {
+	bcryptHasher := defaultBcrypt
+	bcryptHasher.Cost = intFromEnv("BCRYPT_COST", bcryptHasher.Cost, bcrypt.MinCost, bcrypt.MaxCost)
+
+	argonHasher := defaultArgon2id
+	argonHasher.Memory = uint32(intFromEnv("ARGON2_MEMORY_KIB", int(argonHasher.Memory), 8*1024, 4*1024*1024))
+	argonHasher.Iterations = uint32(intFromEnv("ARGON2_ITERATIONS", int(argonHasher.Iterations), 1, 100))
+	argonHasher.Parallelism = uint8(intFromEnv("ARGON2_PARALLELISM", int(argonHasher.Parallelism), 1, 255))
+
+	switch algorithm := os.Getenv("PASSWORD_HASH_ALGORITHM"); algorithm {
+	case "", PasswordAlgorithmArgon2id:
+		passwordHasher = argonHasher
+	case PasswordAlgorithmBcrypt:
+		passwordHasher = bcryptHasher
+	default:
+		log.Fatalf("❌ Unknown PASSWORD_HASH_ALGORITHM %q", algorithm)
+	}
+	verifiers = []PasswordHasher{bcryptHasher, argonHasher}
+	hashSlots = make(chan struct{}, intFromEnv("PASSWORD_HASH_CONCURRENCY", runtime.NumCPU(), 1, 1024))
+	dummyPasswordHash = newDummyPasswordHash()
+}
+
+// HashPassword hashes a plain text password for storage with the configured algorithm
+func HashPassword(password string) (hashed string, err error) {
+	withHashSlot(func() { hashed, err = passwordHasher.Hash(password) })
+	return hashed, err
+}
+
+// VerifyPassword reports whether password matches the stored hash, whichever
+// supported algorithm made it
+func VerifyPassword(encoded, password string) bool {
+	for _, v := range verifiers {
+		if v.Recognises(encoded) {
+			var ok bool
+			var err error
+			withHashSlot(func() { ok, err = v.Verify(encoded, password) })
+			if err != nil {
+				log.Printf("⚠️ failed to verify password hash: %v", err)
+			}
+			return ok
+		}
+	}
+	return false
+}
+
+// dummyPasswordHash is a hash of a random password made with the current
+// hasher, so checking against it costs the same as checking a real password
+var dummyPasswordHash = newDummyPasswordHash()
+
+func newDummyPasswordHash() func() string {
+	return sync.OnceValue(func() string {
+		hashed, err := HashPassword(rand.Text())
+		if err != nil {
+			log.Printf("⚠️ failed to make dummy password hash: %v", err)
+		}
+		return hashed
+	})
+}
+
+// SpendPasswordCheck does the work of VerifyPassword without a stored hash.
+// Call it where a login fails before any password was checked, so response
+// times do not tell which email addresses have accounts.
+func SpendPasswordCheck(password string) {
+	VerifyPassword(dummyPasswordHash(), password)
+}
+
+// PasswordNeedsRehash reports whether a stored hash falls short of the current
+// hashing policy and should be replaced once the password is known
+func PasswordNeedsRehash(encoded string) bool {
+	return !passwordHasher.Recognises(encoded) || passwordHasher.Outdated(encoded)
+}
+
+// intFromEnv parses an integer within [lo, hi] from the environment
+func intFromEnv(key string, fallback, lo, hi int) int {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < lo || n > hi {
+		log.Fatalf("❌ Invalid %s %q, expected %d to %d", key, raw, lo, hi)
+	}
+	return n
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Cheap parameters keep the tests fast; production costs are set by InitPasswordHashing
+var (
+	testBcrypt   = BcryptHasher{Cost: bcrypt.MinCost}
+	testArgon2id = Argon2idHasher{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+)
+
+// useHasher makes hasher the one new hashes are made with for the test
+func useHasher(t *testing.T, hasher PasswordHasher) {
+	t.Helper()
+
+	previousHasher, previousVerifiers := passwordHasher, verifiers
+	passwordHasher = hasher
+	verifiers = []PasswordHasher{testBcrypt, testArgon2id}
+	t.Cleanup(func() {
+		passwordHasher, verifiers = previousHasher, previousVerifiers
+	})
+}
+
+func mustHash(t *testing.T, hasher PasswordHasher, password string) string {
+	t.Helper()
+
+	hashed, err := hasher.Hash(password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hashed
+}
+
+func TestDecodeArgon2id(t *testing.T) {
+	const salt = "c29tZXNhbHRzb21lc2FsdA"
+	const key = "a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2V5a2U"
+
+	tests := []struct {
+		name    string
+		encoded string
+		wantErr bool
+	}{
+		{"valid", "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$" + key, false},
+		{"argon2i", "$argon2i$v=19$m=65536,t=3,p=2$" + salt + "$" + key, true},
+		{"old version", "$argon2id$v=16$m=65536,t=3,p=2$" + salt + "$" + key, true},
+		{"missing key", "$argon2id$v=19$m=65536,t=3,p=2$" + salt, true},
+		{"empty key", "$argon2id$v=19$m=65536,t=3,p=2$" + salt + "$", true},
+		{"bad params", "$argon2id$v=19$m=lots,t=3,p=2$" + salt + "$" + key, true},
+		{"bad salt", "$argon2id$v=19$m=65536,t=3,p=2$not base64!$" + key, true},
+		{"bcrypt", "$2a$10$abcdefghijklmnopqrstuu", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := decodeArgon2id(tt.encoded)
+			if tt.wantErr {
+				if !errors.Is(err, ErrUnknownPasswordHash) {
+					t.Fatalf("err = %v, want ErrUnknownPasswordHash", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeArgon2id: %v", err)
+			}
+			if params.memory != 65536 || params.iterations != 3 || params.parallelism != 2 {
+				t.Fatalf("params = m=%d,t=%d,p=%d, want m=65536,t=3,p=2", params.memory, params.iterations, params.parallelism)
+			}
+			if len(params.salt) != 16 || len(params.key) != 32 {
+				t.Fatalf("salt %d bytes, key %d bytes, want 16 and 32", len(params.salt), len(params.key))
+			}
+		})
+	}
+}
+
+func TestPasswordNeedsRehash(t *testing.T) {
+	bcryptHash := mustHash(t, testBcrypt, "hunter22")
+	argonHash := mustHash(t, testArgon2id, "hunter22")
+
+	moreMemory := testArgon2id
+	moreMemory.Memory *= 2
+	moreIterations := testArgon2id
+	moreIterations.Iterations++
+	higherCost := testBcrypt
+	higherCost.Cost++
+
+	tests := []struct {
+		name    string
+		policy  PasswordHasher
+		encoded string
+		want    bool
+	}{
+		{"argon2id at current parameters", testArgon2id, argonHash, false},
+		{"argon2id after memory increase", moreMemory, argonHash, true},
+		{"argon2id after iterations increase", moreIterations, argonHash, true},
+		{"bcrypt under argon2id policy", testArgon2id, bcryptHash, true},
+		{"bcrypt at current cost", testBcrypt, bcryptHash, false},
+		{"bcrypt after cost increase", higherCost, bcryptHash, true},
+		{"argon2id under bcrypt policy", testBcrypt, argonHash, true},
+		{"unrecognised hash", testArgon2id, "plaintext", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useHasher(t, tt.policy)
+			if got := PasswordNeedsRehash(tt.encoded); got != tt.want {
+				t.Fatalf("PasswordNeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	useHasher(t, testArgon2id)
+
+	tests := []struct {
+		name     string
+		encoded  string
+		password string
+		want     bool
+	}{
+		{"bcrypt match", mustHash(t, testBcrypt, "hunter22"), "hunter22", true},
+		{"bcrypt mismatch", mustHash(t, testBcrypt, "hunter22"), "hunter23", false},
+		{"argon2id match", mustHash(t, testArgon2id, "hunter22"), "hunter22", true},
+		{"argon2id mismatch", mustHash(t, testArgon2id, "hunter22"), "hunter23", false},
+		{"corrupt argon2id", "$argon2id$v=19$m=1024,t=1,p=1$salt$", "hunter22", false},
+		{"unrecognised hash", "hunter22", "hunter22", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPassword(tt.encoded, tt.password); got != tt.want {
+				t.Fatalf("VerifyPassword = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashPasswordUsesCurrentHasher(t *testing.T) {
+	useHasher(t, testArgon2id)
+
+	hashed, err := HashPassword("hunter22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !testArgon2id.Recognises(hashed) || PasswordNeedsRehash(hashed) {
+		t.Fatalf("hash %q was not made with the current hasher", hashed)
+	}
+	if !VerifyPassword(hashed, "hunter22") {
+		t.Fatal("fresh hash does not verify")
+	}
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password policy rule names, returned to clients so they can show which
+// requirement a password missed
+const (
+	PasswordRuleMinLength    = "min_length"
+	PasswordRuleMaxLength    = "max_length"
+	PasswordRuleEntropy      = "entropy"
+	PasswordRulePersonalInfo = "personal_info"
+	PasswordRuleBreached     = "breached"
+	PasswordRuleReused       = "reused"
+)
+
+// bcrypt ignores everything after the 72nd byte
+const bcryptMaxBytes = 72
+
+// PasswordViolation is one rule a password failed
+type PasswordViolation struct {
+	Rule    string
+	Message string
+}
+
+// PasswordSubject is what a password must not contain, such as the account's
+// email or name. Empty fields are ignored.
+type PasswordSubject struct {
+	Email     string
+	FirstName string
+	LastName  string
+}
+
+// PasswordPolicyConfig controls which passwords are accepted
+type PasswordPolicyConfig struct {
+	// MinLength counts characters
+	MinLength int
+	// MaxLength counts bytes
+	MaxLength int
+	// MinEntropyBits is compared against a rough guessability estimate
+	MinEntropyBits float64
+	// DisallowPersonalInfo rejects passwords containing the email or name
+	DisallowPersonalInfo bool
+	// BreachedMinCount rejects passwords seen at least this many times in the breached list
+	BreachedMinCount int
+}
+
+var PasswordPolicy = PasswordPolicyConfig{
+	MinLength:            8,
+	MaxLength:            128,
+	MinEntropyBits:       35,
+	DisallowPersonalInfo: true,
+	BreachedMinCount:     1,
+}
+
+var breachedPasswords BreachedPasswordList
+
+// InitPasswordPolicy reads the policy from the environment and loads the
+// breached password list. It must run after InitPasswordHashing.
+func InitPasswordPolicy() {
+	policy := PasswordPolicy
+	policy.MinLength = intFromEnv("PASSWORD_MIN_LENGTH", policy.MinLength, 1, 1024)
+	policy.MaxLength = intFromEnv("PASSWORD_MAX_LENGTH", policy.MaxLength, policy.MinLength, 4096)
+	policy.MinEntropyBits = float64(intFromEnv("PASSWORD_MIN_ENTROPY_BITS", int(policy.MinEntropyBits), 0, 256))
+	policy.BreachedMinCount = intFromEnv("BREACHED_PASSWORD_MIN_COUNT", policy.BreachedMinCount, 1, math.MaxInt32)
+	if raw := os.Getenv("PASSWORD_DISALLOW_PERSONAL_INFO"); raw != "" {
+		disallow, err := strconv.ParseBool(raw)
+		if err != nil {
+			log.Fatalf("❌ Invalid PASSWORD_DISALLOW_PERSONAL_INFO %q", raw)
+		}
+		policy.DisallowPersonalInfo = disallow
+	}
+
+	// Longer passwords would be silently truncated
+	if _, ok := passwordHasher.(BcryptHasher); ok && policy.MaxLength > bcryptMaxBytes {
+		policy.MaxLength = bcryptMaxBytes
+	}
+	PasswordPolicy = policy
+
+	path := os.Getenv("BREACHED_PASSWORDS_PATH")
+	if path == "" {
+		log.Println("⚠️ BREACHED_PASSWORDS_PATH not set, passwords will not be checked against known breaches")
+		return
+	}
+
+	list, err := OpenBreachedPasswordList(path)
+	if err != nil {
+		log.Fatal("❌ Failed to load breached passwords:", err)
+	}
+	breachedPasswords = list
+	log.Println("✅ Breached password list loaded from", path)
+}
+
+// ValidatePassword checks password against the policy and returns every rule
+// it breaks; an empty result means the password is acceptable
+func ValidatePassword(password string, subject PasswordSubject) []PasswordViolation {
+	p := PasswordPolicy
+	var violations []PasswordViolation
+
+	if utf8.RuneCountInString(password) < p.MinLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %d characters", p.MinLength),
+		})
+	}
+	if len(password) > p.MaxLength {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %d bytes", p.MaxLength),
+		})
+	}
+
+	if p.MinEntropyBits > 0 && PasswordEntropy(password) < p.MinEntropyBits {
+		violations = append(violations, PasswordViolation{
+			Rule:    PasswordRuleEntropy,
+			Message: "Password is too easy to guess, make it longer or mix in other kinds of characters",
+		})
+	}
+
+	if p.DisallowPersonalInfo {
+		if part := personalInfoIn(password, subject); part != "" {
+			violations = append(violations, PasswordViolation{
+				Rule:    PasswordRulePersonalInfo,
+				Message: fmt.Sprintf("Password must not contain your %s", part),
+			})
+		}
+	}
+
+	if breachedPasswords != nil {
+		count, err := breachedPasswords.Count(password)
+		if err != nil {
+			// Fail open: an unreadable list should not stop people changing passwords
+			log.Printf("⚠️ failed to check breached passwords: %v", err)
+		} else if count >= p.BreachedMinCount {
+			violations = append(violations, PasswordViolation{
+				Rule:    PasswordRuleBreached,
+				Message: "Password has appeared in a data breach, choose a different one",
+			})
+		}
+	}
+
+	return violations
+}
+
+// PasswordEntropy estimates the bits of entropy in a password from the size of
+// the character classes it draws on. Characters that repeat or continue a run
+// from the previous one ("aaa", "abc", "321") add only a single bit.
+func PasswordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < utf8.RuneSelf && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(pool))
+	var bits float64
+	prev := rune(-1)
+	for _, r := range password {
+		if d := r - prev; d >= -1 && d <= 1 {
+			bits++
+		} else {
+			bits += perChar
+		}
+		prev = r
+	}
+	return bits
+}
+
+// personalInfoIn names the part of subject the password contains, if any.
+// Parts shorter than three characters are too common to be meaningful.
+func personalInfoIn(password string, subject PasswordSubject) string {
+	lowered := strings.ToLower(password)
+
+	local, _, _ := strings.Cut(subject.Email, "@")
+	for _, part := range []struct{ name, value string }{
+		{"email", local},
+		{"name", subject.FirstName},
+		{"name", subject.LastName},
+	} {
+		value := strings.ToLower(strings.TrimSpace(part.value))
+		if utf8.RuneCountInString(value) >= 3 && strings.Contains(lowered, value) {
+			return part.name
+		}
+	}
+	return ""
+}
+
+// BreachedPasswordList reports how often a password appears in a breach corpus
+type BreachedPasswordList interface {
+	Count(password string) (int, error)
+}
+
+// OpenBreachedPasswordList loads a breach corpus in the Pwned Passwords
+// k-anonymity format. path may be a directory of range files, one per
+// five character SHA-1 prefix (e.g. "5BAA6" or "5BAA6.txt") holding
+// "SUFFIX:COUNT" lines, read on demand; or a single file of full
+// "HASH:COUNT" lines, loaded into memory.
+func OpenBreachedPasswordList(path string) (BreachedPasswordList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return breachedPasswordDir(path), nil
+	}
+	return loadBreachedPasswordFile(path)
+}
+
+// breachedPasswordPrefix splits a password's upper-case SHA-1 into the
+// five character range prefix and the remaining suffix
+func breachedPasswordPrefix(password string) (string, string) {
+	sum := sha1.Sum([]byte(password))
+	h := strings.ToUpper(hex.EncodeToString(sum[:]))
+	return h[:5], h[5:]
+}
+
+type breachedPasswordDir string
+
+func (d breachedPasswordDir) Count(password string) (int, error) {
+	prefix, suffix := breachedPasswordPrefix(password)
+
+	for _, name := range []string{prefix, prefix + ".txt"} {
+		f, err := os.Open(filepath.Join(string(d), name))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			hash, count, ok := parseBreachedLine(scanner.Text())
+			if ok && strings.EqualFold(hash, suffix) {
+				return count, nil
+			}
+		}
+		return 0, scanner.Err()
+	}
+	return 0, nil
+}
+
+// breachedPasswordMap holds a whole corpus keyed by range prefix, then suffix
+type breachedPasswordMap map[string]map[string]int
+
+func (m breachedPasswordMap) Count(password string) (int, error) {
+	prefix, suffix := breachedPasswordPrefix(password)
+	return m[prefix][suffix], nil
+}
+
+func loadBreachedPasswordFile(path string) (breachedPasswordMap, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	list := breachedPasswordMap{}
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		hash, count, ok := parseBreachedLine(scanner.Text())
+		if !ok {
+			continue
+		}
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("line %d: expected a full SHA-1 hash", line)
+		}
+		hash = strings.ToUpper(hash)
+		if list[hash[:5]] == nil {
+			list[hash[:5]] = map[string]int{}
+		}
+		list[hash[:5]][hash[5:]] = count
+	}
+	return list, scanner.Err()
+}
+
+// parseBreachedLine reads "HASH:COUNT", treating a missing count as one
+// sighting. Blank lines and comments are skipped.
+func parseBreachedLine(line string) (string, int, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", 0, false
+	}
+
+	hash, rawCount, found := strings.Cut(line, ":")
+	count := 1
+	if found {
+		n, err := strconv.Atoi(strings.TrimSpace(rawCount))
+		if err != nil {
+			return "", 0, false
+		}
+		count = n
+	}
+	return strings.TrimSpace(hash), count, true
+}
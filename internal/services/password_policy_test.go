@@ -0,0 +1,220 @@
+package services
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	// SHA-1 of "password" and "letmein", split into range prefix and suffix
+	passwordPrefix, passwordSuffix = "5BAA6", "1E4C9B93F3F0682250B6CF8331B7EE68FD8"
+	letmeinPrefix, letmeinSuffix   = "B7A87", "5FC1EA228B9061041B7CEC4BD3C52AB3CE3"
+)
+
+// usePolicy sets the password policy and breached list for the test
+func usePolicy(t *testing.T, policy PasswordPolicyConfig, list BreachedPasswordList) {
+	t.Helper()
+
+	previousPolicy, previousList := PasswordPolicy, breachedPasswords
+	PasswordPolicy, breachedPasswords = policy, list
+	t.Cleanup(func() {
+		PasswordPolicy, breachedPasswords = previousPolicy, previousList
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func rules(violations []PasswordViolation) []string {
+	var names []string
+	for _, v := range violations {
+		names = append(names, v.Rule)
+	}
+	return names
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	lower := math.Log2(26)
+	mixed := math.Log2(26 + 26 + 10 + 33)
+
+	tests := []struct {
+		password string
+		want     float64
+	}{
+		{"", 0},
+		{"q", lower},
+		{"qwzx", 4 * lower},
+		// Repeats and runs add a bit each after the first character
+		{"aaaa", lower + 3},
+		{"abcd", lower + 3},
+		{"dcba", lower + 3},
+		{"Qz7!", 4 * mixed},
+	}
+	for _, tt := range tests {
+		if got := PasswordEntropy(tt.password); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("PasswordEntropy(%q) = %.2f, want %.2f", tt.password, got, tt.want)
+		}
+	}
+
+	if PasswordEntropy("correct horse battery staple") <= PasswordEntropy("Tr0ub4dor&3") {
+		t.Error("a long passphrase should score above a short mangled word")
+	}
+}
+
+func TestPersonalInfoIn(t *testing.T) {
+	subject := PasswordSubject{Email: "jane.doe@example.com", FirstName: "Jo", LastName: "Smith"}
+
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"Jane.Doe2024", "email"},
+		{"xxSMITHxx", "name"},
+		// Parts shorter than three characters are ignored
+		{"Jo-Jo-Jo-Jo", ""},
+		{"example.com!", ""},
+		{"unrelated passphrase", ""},
+	}
+	for _, tt := range tests {
+		if got := personalInfoIn(tt.password, subject); got != tt.want {
+			t.Errorf("personalInfoIn(%q) = %q, want %q", tt.password, got, tt.want)
+		}
+	}
+
+	if got := personalInfoIn("anything", PasswordSubject{}); got != "" {
+		t.Errorf("empty subject matched %q", got)
+	}
+}
+
+func TestValidatePasswordLength(t *testing.T) {
+	usePolicy(t, PasswordPolicyConfig{MinLength: 8, MaxLength: 16}, nil)
+
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"too short", "seven77", []string{PasswordRuleMinLength}},
+		{"minimum", "eight888", nil},
+		// The minimum counts characters, the maximum bytes
+		{"multibyte minimum", strings.Repeat("é", 8), nil},
+		{"too many bytes", strings.Repeat("é", 9), []string{PasswordRuleMaxLength}},
+		{"maximum", strings.Repeat("x", 16), nil},
+		{"too long", strings.Repeat("x", 17), []string{PasswordRuleMaxLength}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(ValidatePassword(tt.password, PasswordSubject{})); !slices.Equal(got, tt.want) {
+				t.Fatalf("rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePasswordRules(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, passwordPrefix+".txt"), passwordSuffix+":3861493\n")
+
+	usePolicy(t, PasswordPolicyConfig{
+		MinLength:            8,
+		MaxLength:            128,
+		MinEntropyBits:       35,
+		DisallowPersonalInfo: true,
+		BreachedMinCount:     1,
+	}, breachedPasswordDir(dir))
+
+	subject := PasswordSubject{Email: "jane.doe@example.com", FirstName: "Jane"}
+	tests := []struct {
+		name     string
+		password string
+		want     []string
+	}{
+		{"acceptable", "violet-harbour-27", nil},
+		{"guessable", "aaaaaaaaaaaa", []string{PasswordRuleEntropy}},
+		{"personal", "violet-jane-27", []string{PasswordRulePersonalInfo}},
+		{"breached", "password", []string{PasswordRuleEntropy, PasswordRuleBreached}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rules(ValidatePassword(tt.password, subject)); !slices.Equal(got, tt.want) {
+				t.Fatalf("rules = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitPasswordPolicyClampsBcrypt(t *testing.T) {
+	t.Setenv("BREACHED_PASSWORDS_PATH", "")
+	t.Setenv("PASSWORD_MAX_LENGTH", "128")
+	usePolicy(t, PasswordPolicy, nil)
+
+	useHasher(t, testBcrypt)
+	InitPasswordPolicy()
+	if PasswordPolicy.MaxLength != bcryptMaxBytes {
+		t.Fatalf("bcrypt MaxLength = %d, want %d", PasswordPolicy.MaxLength, bcryptMaxBytes)
+	}
+
+	useHasher(t, testArgon2id)
+	InitPasswordPolicy()
+	if PasswordPolicy.MaxLength != 128 {
+		t.Fatalf("argon2id MaxLength = %d, want 128", PasswordPolicy.MaxLength)
+	}
+}
+
+func TestBreachedPasswordDir(t *testing.T) {
+	dir := t.TempDir()
+	// Range files with and without the .txt extension
+	writeFile(t, filepath.Join(dir, passwordPrefix+".txt"), "0000000000000000000000000000000000A:2\n"+strings.ToLower(passwordSuffix)+":3861493\n")
+	writeFile(t, filepath.Join(dir, letmeinPrefix), letmeinSuffix+"\n")
+
+	list, err := OpenBreachedPasswordList(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBreachedCounts(t, list, map[string]int{"password": 3861493, "letmein": 1, "violet-harbour-27": 0})
+}
+
+func TestBreachedPasswordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	writeFile(t, path, strings.Join([]string{
+		"# full hashes",
+		"",
+		passwordPrefix + passwordSuffix + ":10",
+		strings.ToLower(letmeinPrefix+letmeinSuffix) + ":4",
+	}, "\n"))
+
+	list, err := OpenBreachedPasswordList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertBreachedCounts(t, list, map[string]int{"password": 10, "letmein": 4, "violet-harbour-27": 0})
+
+	// A range file is not a valid single file corpus
+	writeFile(t, path, passwordSuffix+":10\n")
+	if _, err := OpenBreachedPasswordList(path); err == nil {
+		t.Fatal("accepted a file of hash suffixes")
+	}
+}
+
+func assertBreachedCounts(t *testing.T, list BreachedPasswordList, want map[string]int) {
+	t.Helper()
+
+	for password, count := range want {
+		got, err := list.Count(password)
+		if err != nil {
+			t.Fatalf("Count(%q): %v", password, err)
+		}
+		if got != count {
+			t.Errorf("Count(%q) = %d, want %d", password, got, count)
+		}
+	}
+}
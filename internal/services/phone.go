@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	phoneOTPTTL         = 10 * time.Minute
+	phoneOTPResendAfter = time.Minute
+	maxPhoneOTPAttempts = 5
+)
+
+var (
+	ErrInvalidPhone         = errors.New("invalid phone number")
+	ErrNoPhone              = errors.New("no phone number on the account")
+	ErrPhoneAlreadyVerified = errors.New("phone number already verified")
+	ErrPhoneOTPTooSoon      = errors.New("a code was sent recently")
+	ErrInvalidPhoneOTP      = errors.New("invalid verification code")
+	ErrPhoneOTPExpired      = errors.New("verification code expired")
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhone converts user input such as "(415) 555-0100" or
+// "0044 20 7946 0958" to E.164. Numbers without an international prefix get
+// PHONE_DEFAULT_COUNTRY_CODE, after dropping a leading trunk 0.
+func NormalizePhone(raw string) (string, error) {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(raw) {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == '+' && i == 0:
+			b.WriteRune(r)
+		case strings.ContainsRune(" -.()/", r):
+			// separators
+		default:
+			return "", ErrInvalidPhone
+		}
+	}
+	phone := b.String()
+
+	switch {
+	case strings.HasPrefix(phone, "+"):
+	case strings.HasPrefix(phone, "00"):
+		phone = "+" + phone[2:]
+	default:
+		countryCode := strings.TrimPrefix(os.Getenv("PHONE_DEFAULT_COUNTRY_CODE"), "+")
+		if countryCode == "" {
+			return "", fmt.Errorf("%w: include the country code", ErrInvalidPhone)
+		}
+		phone = "+" + countryCode + strings.TrimPrefix(phone, "0")
+	}
+
+	if !e164Pattern.MatchString(phone) {
+		return "", ErrInvalidPhone
+	}
+	return phone, nil
+}
+
+// SetPhone replaces the user's number. The new number starts unverified and
+// any code sent to the old one stops working.
+func SetPhone(userID uuid.UUID, phone string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"phone":          phone,
+			"phone_verified": false,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.PhoneVerification{}).Error
+	})
+}
+
+// SendPhoneOTP texts a fresh six digit code to the user's phone
+func SendPhoneOTP(ctx context.Context, user models.User, now time.Time) error {
+	if user.Phone == nil || *user.Phone == "" {
+		return ErrNoPhone
+	}
+	if user.PhoneVerified {
+		return ErrPhoneAlreadyVerified
+	}
+
+	var pending models.PhoneVerification
+	err := config.DB.Where("user_id = ?", user.ID).First(&pending).Error
+	if err == nil && now.Sub(pending.SentAt) < phoneOTPResendAfter {
+		return ErrPhoneOTPTooSoon
+	}
+
+	code, err := randomDigits(6)
+	if err != nil {
+		return err
+	}
+
+	verification := models.PhoneVerification{
+		UserID:    user.ID,
+		Phone:     *user.Phone,
+		CodeHash:  phoneOTPHash(user.ID, code),
+		Attempts:  0,
+		ExpiresAt: now.Add(phoneOTPTTL),
+		SentAt:    now,
+	}
+	if err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"phone", "code_hash", "attempts", "expires_at", "sent_at"}),
+	}).Create(&verification).Error; err != nil {
+		return err
+	}
+
+	return SMS.Send(ctx, SMSMessage{
+		To:   *user.Phone,
+		Body: fmt.Sprintf("%s is your %s verification code. It expires in 10 minutes.", code, AppName()),
+	})
+}
+
+// VerifyPhoneOTP checks a code and marks the phone verified. After
+// maxPhoneOTPAttempts wrong codes the pending code is discarded.
+func VerifyPhoneOTP(userID uuid.UUID, code string, now time.Time) error {
+	var pending models.PhoneVerification
+	if err := config.DB.Where("user_id = ?", userID).First(&pending).Error; err != nil {
+		return ErrPhoneOTPExpired
+	}
+
+	// Claim an attempt before comparing the code, so concurrent guesses
+	// cannot all slip in under the limit
+	result := config.DB.Model(&models.PhoneVerification{}).
+		Where("id = ? AND attempts < ? AND expires_at > ?", pending.ID, maxPhoneOTPAttempts, now).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		config.DB.Delete(&pending)
+		return ErrPhoneOTPExpired
+	}
+
+	if !constantTimeEqual(phoneOTPHash(userID, code), pending.CodeHash) {
+		return ErrInvalidPhoneOTP
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.PhoneVerification{}, "id = ?", pending.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPhoneOTPExpired
+		}
+
+		// Only verify the number the code was sent to
+		result = tx.Model(&models.User{}).
+			Where("id = ? AND phone = ?", userID, pending.Phone).
+			Update("phone_verified", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPhoneOTPExpired
+		}
+		return nil
+	})
+}
+
+// phoneOTPHash binds the code to the user so equal codes hash differently
+func phoneOTPHash(userID uuid.UUID, code string) string {
+	return HashToken(userID.String() + ":" + code)
+}
+
+func randomDigits(n int) (string, error) {
+	max := big.NewInt(1)
+	for range n {
+		max.Mul(max, big.NewInt(10))
+	}
+	v, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", n, v), nil
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+	"build-in-public/internal/testutil"
+)
+
+const testPhone = "+14155550100"
+
+// useMemorySMS swaps in a MemorySMSSender for the test
+func useMemorySMS(t *testing.T) *MemorySMSSender {
+	t.Helper()
+
+	previous := SMS
+	sender := &MemorySMSSender{}
+	SMS = sender
+	t.Cleanup(func() { SMS = previous })
+	return sender
+}
+
+// lastCode returns the code in the most recent text, which starts the body
+func lastCode(t *testing.T, sender *MemorySMSSender) string {
+	t.Helper()
+
+	messages := sender.Messages()
+	if len(messages) == 0 {
+		t.Fatal("no SMS sent")
+	}
+	msg := messages[len(messages)-1]
+	if msg.To != testPhone {
+		t.Fatalf("sent to %s, want %s", msg.To, testPhone)
+	}
+	return msg.Body[:6]
+}
+
+// wrongCode returns a six digit code that differs from code
+func wrongCode(code string) string {
+	if code == "000000" {
+		return "111111"
+	}
+	return "000000"
+}
+
+func createPhoneUser(t *testing.T) models.User {
+	t.Helper()
+
+	return testutil.CreateUser(t, func(u *models.User) {
+		phone := testPhone
+		u.Phone = &phone
+	})
+}
+
+func phoneVerified(t *testing.T, user models.User) bool {
+	t.Helper()
+
+	var got models.User
+	if err := config.DB.First(&got, "id = ?", user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	return got.PhoneVerified
+}
+
+func TestVerifyPhoneOTP(t *testing.T) {
+	testutil.DB(t)
+	sender := useMemorySMS(t)
+	now := time.Now()
+	user := createPhoneUser(t)
+
+	if err := SendPhoneOTP(context.Background(), user, now); err != nil {
+		t.Fatalf("SendPhoneOTP: %v", err)
+	}
+	code := lastCode(t, sender)
+
+	if err := VerifyPhoneOTP(user.ID, wrongCode(code), now); !errors.Is(err, ErrInvalidPhoneOTP) {
+		t.Fatalf("wrong code: err = %v, want ErrInvalidPhoneOTP", err)
+	}
+	if err := VerifyPhoneOTP(user.ID, code, now); err != nil {
+		t.Fatalf("VerifyPhoneOTP: %v", err)
+	}
+	if !phoneVerified(t, user) {
+		t.Fatal("phone not marked verified")
+	}
+
+	// A code is consumed by its first success
+	if err := VerifyPhoneOTP(user.ID, code, now); !errors.Is(err, ErrPhoneOTPExpired) {
+		t.Fatalf("reused code: err = %v, want ErrPhoneOTPExpired", err)
+	}
+}
+
+func TestVerifyPhoneOTPExpired(t *testing.T) {
+	testutil.DB(t)
+	sender := useMemorySMS(t)
+	now := time.Now()
+	user := createPhoneUser(t)
+
+	if err := SendPhoneOTP(context.Background(), user, now); err != nil {
+		t.Fatal(err)
+	}
+	code := lastCode(t, sender)
+
+	if err := VerifyPhoneOTP(user.ID, code, now.Add(phoneOTPTTL+time.Second)); !errors.Is(err, ErrPhoneOTPExpired) {
+		t.Fatalf("err = %v, want ErrPhoneOTPExpired", err)
+	}
+	if phoneVerified(t, user) {
+		t.Fatal("expired code verified the phone")
+	}
+}
+
+func TestVerifyPhoneOTPLockout(t *testing.T) {
+	testutil.DB(t)
+	sender := useMemorySMS(t)
+	now := time.Now()
+	user := createPhoneUser(t)
+
+	if err := SendPhoneOTP(context.Background(), user, now); err != nil {
+		t.Fatal(err)
+	}
+	code := lastCode(t, sender)
+
+	for i := range maxPhoneOTPAttempts {
+		if err := VerifyPhoneOTP(user.ID, wrongCode(code), now); !errors.Is(err, ErrInvalidPhoneOTP) {
+			t.Fatalf("attempt %d: err = %v, want ErrInvalidPhoneOTP", i+1, err)
+		}
+	}
+
+	// Out of attempts, so even the right code is refused
+	if err := VerifyPhoneOTP(user.ID, code, now); !errors.Is(err, ErrPhoneOTPExpired) {
+		t.Fatalf("err = %v, want ErrPhoneOTPExpired", err)
+	}
+	if phoneVerified(t, user) {
+		t.Fatal("locked out code verified the phone")
+	}
+}
+
+func TestSendPhoneOTPCooldown(t *testing.T) {
+	testutil.DB(t)
+	sender := useMemorySMS(t)
+	now := time.Now()
+	user := createPhoneUser(t)
+
+	if err := SendPhoneOTP(context.Background(), user, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := SendPhoneOTP(context.Background(), user, now.Add(phoneOTPResendAfter/2)); !errors.Is(err, ErrPhoneOTPTooSoon) {
+		t.Fatalf("err = %v, want ErrPhoneOTPTooSoon", err)
+	}
+	if got := len(sender.Messages()); got != 1 {
+		t.Fatalf("sent %d texts, want 1", got)
+	}
+
+	later := now.Add(phoneOTPResendAfter)
+	if err := SendPhoneOTP(context.Background(), user, later); err != nil {
+		t.Fatalf("resend after cooldown: %v", err)
+	}
+	if got := len(sender.Messages()); got != 2 {
+		t.Fatalf("sent %d texts, want 2", got)
+	}
+
+	// The resent code replaces the first one
+	if err := VerifyPhoneOTP(user.ID, lastCode(t, sender), later); err != nil {
+		t.Fatalf("VerifyPhoneOTP: %v", err)
+	}
+}
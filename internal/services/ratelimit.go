@@ -0,0 +1,171 @@
+package services
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"gorm.io/gorm/clause"
+)
+
+// RateLimitStore counts events per key. The memory store is per process;
+// shared stores let several app instances enforce one limit.
+type RateLimitStore interface {
+	// Hit records an event for key and reports whether it is within limit
+	// events per window, and if not, how long until the next one is allowed
+	Hit(key string, limit int, window time.Duration) (bool, time.Duration, error)
+}
+
+var (
+	rateLimitStoreMu sync.RWMutex
+	rateLimitStore   RateLimitStore = NewMemoryRateLimitStore()
+)
+
+// InitRateLimiting selects the store behind every RateLimiter.
+// RATE_LIMIT_BACKEND is "memory" (default) or "database".
+func InitRateLimiting() {
+	switch backend := os.Getenv("RATE_LIMIT_BACKEND"); backend {
+	case "", "memory":
+		SetRateLimitStore(NewMemoryRateLimitStore())
+	case "database":
+		SetRateLimitStore(&DBRateLimitStore{})
+	default:
+		log.Fatalf("❌ Unknown RATE_LIMIT_BACKEND %q", backend)
+	}
+}
+
+// SetRateLimitStore replaces the store used by all limiters
+func SetRateLimitStore(store RateLimitStore) {
+	rateLimitStoreMu.Lock()
+	defer rateLimitStoreMu.Unlock()
+	rateLimitStore = store
+}
+
+func currentRateLimitStore() RateLimitStore {
+	rateLimitStoreMu.RLock()
+	defer rateLimitStoreMu.RUnlock()
+	return rateLimitStore
+}
+
+// RateLimiter allows at most Limit events per key within Window
+type RateLimiter struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+// NewRateLimiter creates a limiter; name namespaces its keys in shared stores
+func NewRateLimiter(name string, limit int, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		Name:   name,
+		Limit:  limit,
+		Window: window,
+	}
+}
+
+// Allow records an event for key and reports whether it is within the limit.
+// When it is not, the returned duration says how long until the next event is allowed.
+// If the store is unavailable the event is allowed, so an outage doesn't lock everyone out.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	allowed, retryAfter, err := currentRateLimitStore().Hit(l.Name+":"+key, l.Limit, l.Window)
+	if err != nil {
+		log.Printf("⚠️ rate limit store error for %s: %v", l.Name, err)
+		return true, 0
+	}
+	return allowed, retryAfter
+}
+
+// MemoryRateLimitStore is a sliding window log kept in process memory
+type MemoryRateLimitStore struct {
+	mu   sync.Mutex
+	hits map[string][]time.Time
+	now  func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		hits: make(map[string][]time.Time),
+		now:  time.Now,
+	}
+}
+
+func (s *MemoryRateLimitStore) Hit(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := s.now()
+	cutoff := now.Add(-window)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	hits := s.hits[key]
+	kept := hits[:0]
+	for _, t := range hits {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+
+	if len(kept) >= limit {
+		s.hits[key] = kept
+		return false, kept[0].Sub(cutoff), nil
+	}
+
+	s.hits[key] = append(kept, now)
+	s.sweep(cutoff)
+	return true, 0, nil
+}
+
+// sweep drops keys whose events have all left the window. Called with mu held.
+func (s *MemoryRateLimitStore) sweep(cutoff time.Time) {
+	if len(s.hits) < 1024 {
+		return
+	}
+	for key, hits := range s.hits {
+		if len(hits) == 0 || !hits[len(hits)-1].After(cutoff) {
+			delete(s.hits, key)
+		}
+	}
+}
+
+// DBRateLimitStore keeps fixed window counters in Postgres, shared by every
+// instance using the database
+type DBRateLimitStore struct{}
+
+func (s *DBRateLimitStore) Hit(key string, limit int, window time.Duration) (bool, time.Duration, error) {
+	now := time.Now()
+	windowStart := now.Truncate(window)
+	windowEnd := windowStart.Add(window)
+
+	bucket := models.RateLimitBucket{
+		Key:         key,
+		WindowStart: windowStart,
+		Count:       1,
+		ExpiresAt:   windowEnd,
+	}
+
+	// Upsert and read the new count in one statement so concurrent hits
+	// from other instances are counted exactly
+	err := config.DB.Clauses(
+		clause.OnConflict{
+			Columns:   []clause.Column{{Name: "key"}, {Name: "window_start"}},
+			DoUpdates: clause.Assignments(map[string]any{"count": clause.Expr{SQL: "rate_limit_buckets.count + 1"}}),
+		},
+		clause.Returning{Columns: []clause.Column{{Name: "count"}}},
+	).Create(&bucket).Error
+	if err != nil {
+		return false, 0, err
+	}
+
+	// Expired windows are cleaned up opportunistically
+	if bucket.Count == 1 {
+		config.DB.Where("expires_at < ?", now).Delete(&models.RateLimitBucket{})
+	}
+
+	if bucket.Count > limit {
+		return false, windowEnd.Sub(now), nil
+	}
+	return true, 0, nil
+}
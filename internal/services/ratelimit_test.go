@@ -0,0 +1,69 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+
+	hit := func(key string, at time.Duration) (bool, time.Duration) {
+		t.Helper()
+		now = start.Add(at)
+		allowed, retryAfter, err := store.Hit(key, 3, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allowed, retryAfter
+	}
+
+	for _, at := range []time.Duration{0, 10 * time.Second, 20 * time.Second} {
+		if allowed, _ := hit("a", at); !allowed {
+			t.Fatalf("hit at %s refused within the limit", at)
+		}
+	}
+
+	// The window slides: the next slot opens when the oldest hit leaves it
+	if allowed, retryAfter := hit("a", 30*time.Second); allowed || retryAfter != 30*time.Second {
+		t.Fatalf("fourth hit = %v, retry after %s; want refused, 30s", allowed, retryAfter)
+	}
+	if allowed, retryAfter := hit("a", 50*time.Second); allowed || retryAfter != 10*time.Second {
+		t.Fatalf("hit at 50s = %v, retry after %s; want refused, 10s", allowed, retryAfter)
+	}
+
+	// Other keys have their own window
+	if allowed, _ := hit("b", 50*time.Second); !allowed {
+		t.Fatal("another key was limited")
+	}
+
+	// Refused hits are not counted, so one slot frees up at 60s
+	if allowed, _ := hit("a", time.Minute); !allowed {
+		t.Fatal("hit refused after the oldest left the window")
+	}
+	if allowed, retryAfter := hit("a", time.Minute); allowed || retryAfter != 10*time.Second {
+		t.Fatalf("hit at 60s = %v, retry after %s; want refused, 10s", allowed, retryAfter)
+	}
+}
+
+func TestRateLimiterNamespacesKeys(t *testing.T) {
+	previous := currentRateLimitStore()
+	SetRateLimitStore(NewMemoryRateLimitStore())
+	t.Cleanup(func() { SetRateLimitStore(previous) })
+
+	login := NewRateLimiter("login", 1, time.Minute)
+	signup := NewRateLimiter("signup", 1, time.Minute)
+
+	if allowed, _ := login.Allow("203.0.113.7"); !allowed {
+		t.Fatal("first login refused")
+	}
+	if allowed, retryAfter := login.Allow("203.0.113.7"); allowed || retryAfter <= 0 {
+		t.Fatalf("second login = %v, retry after %s; want refused with a delay", allowed, retryAfter)
+	}
+	if allowed, _ := signup.Allow("203.0.113.7"); !allowed {
+		t.Fatal("a different limiter shared the login count")
+	}
+}
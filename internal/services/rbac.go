@@ -0,0 +1,234 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownRole      = errors.New("unknown role")
+	ErrCannotModifySelf = errors.New("cannot change your own role or suspension")
+)
+
+// defaultPermissions describes what each built-in role may do. SeedRoles
+// writes it to the database on startup, which is the source of truth.
+var defaultPermissions = map[models.RoleName][]models.PermissionName{
+	models.RoleUser:      {},
+	models.RoleModerator: {models.PermContentModerate, models.PermUsersRead, models.PermUsersSuspend},
+	models.RoleCollegeAdmin: {
+		models.PermCollegeManage, models.PermUsersRead,
+	},
+	models.RoleAdmin: {
+		models.PermUsersRead, models.PermUsersSuspend, models.PermUsersAssignRole,
+		models.PermContentModerate, models.PermCollegeManage, models.PermAuditRead,
+		models.PermUsersImpersonate,
+	},
+}
+
+// roleRanks orders the built-in roles for actions one user takes against
+// another. Roles at the same rank cannot act against each other, and unknown
+// roles rank lowest.
+var roleRanks = map[models.RoleName]int{
+	models.RoleUser:         0,
+	models.RoleModerator:    1,
+	models.RoleCollegeAdmin: 1,
+	models.RoleAdmin:        2,
+}
+
+var roleDescriptions = map[models.RoleName]string{
+	models.RoleUser:         "Regular member",
+	models.RoleModerator:    "Moderates posts and can suspend users",
+	models.RoleCollegeAdmin: "Manages the members of their college",
+	models.RoleAdmin:        "Full access to the admin area",
+}
+
+var permissionDescriptions = map[models.PermissionName]string{
+	models.PermUsersRead:        "List and inspect users",
+	models.PermUsersSuspend:     "Suspend and reinstate users",
+	models.PermUsersAssignRole:  "Change a user's role",
+	models.PermContentModerate:  "Hide or remove posts",
+	models.PermCollegeManage:    "Manage a college and its members",
+	models.PermAuditRead:        "Search the security audit log",
+	models.PermUsersImpersonate: "Sign in as a user to see what they see",
+}
+
+// rolePermissionsTTL bounds how long permission changes made directly in the
+// database take to apply
+const rolePermissionsTTL = time.Minute
+
+var (
+	rolePermissionsMu     sync.RWMutex
+	rolePermissions       map[models.RoleName][]models.PermissionName
+	rolePermissionsLoaded time.Time
+)
+
+// SeedRoles creates the built-in roles and permissions and grants each role
+// its default permissions. Permissions added to a role by hand are kept.
+func SeedRoles() {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for name, description := range permissionDescriptions {
+			permission := models.Permission{Name: name, Description: description}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description"}),
+			}).Create(&permission).Error; err != nil {
+				return err
+			}
+		}
+
+		for name, permissionNames := range defaultPermissions {
+			role := models.Role{Name: name, Description: roleDescriptions[name]}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "name"}},
+				DoUpdates: clause.AssignmentColumns([]string{"description", "updated_at"}),
+			}).Create(&role).Error; err != nil {
+				return err
+			}
+
+			if len(permissionNames) == 0 {
+				continue
+			}
+			permissions := make([]models.Permission, 0, len(permissionNames))
+			for _, p := range permissionNames {
+				permissions = append(permissions, models.Permission{Name: p})
+			}
+			if err := tx.Model(&role).Omit("Permissions.*").
+				Association("Permissions").Append(permissions); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatal("❌ Failed to seed roles:", err)
+	}
+
+	if err := loadRolePermissions(); err != nil {
+		log.Fatal("❌ Failed to load role permissions:", err)
+	}
+}
+
+func loadRolePermissions() error {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Find(&roles).Error; err != nil {
+		return err
+	}
+
+	loaded := make(map[models.RoleName][]models.PermissionName, len(roles))
+	for _, role := range roles {
+		names := make([]models.PermissionName, 0, len(role.Permissions))
+		for _, p := range role.Permissions {
+			names = append(names, p.Name)
+		}
+		loaded[role.Name] = names
+	}
+
+	rolePermissionsMu.Lock()
+	rolePermissions = loaded
+	rolePermissionsLoaded = time.Now()
+	rolePermissionsMu.Unlock()
+	return nil
+}
+
+// HasPermission reports whether the role grants the permission
+func HasPermission(role models.RoleName, permission models.PermissionName) bool {
+	rolePermissionsMu.RLock()
+	stale := time.Since(rolePermissionsLoaded) > rolePermissionsTTL
+	rolePermissionsMu.RUnlock()
+
+	if stale {
+		if err := loadRolePermissions(); err != nil {
+			log.Printf("⚠️ failed to reload role permissions: %v", err)
+		}
+	}
+
+	rolePermissionsMu.RLock()
+	defer rolePermissionsMu.RUnlock()
+	return slices.Contains(rolePermissions[role], permission)
+}
+
+// RoleExists reports whether a role with this name is defined
+func RoleExists(role models.RoleName) bool {
+	rolePermissionsMu.RLock()
+	defer rolePermissionsMu.RUnlock()
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// AssignRole changes a user's role
+func AssignRole(actorID, userID uuid.UUID, role models.RoleName) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+	if !RoleExists(role) {
+		return fmt.Errorf("%w: %s", ErrUnknownRole, role)
+	}
+
+	result := config.DB.Model(&models.User{}).Where("id = ?", userID).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
+
+// Outranks reports whether a user with role actor may act against one with role target
+func Outranks(actor, target models.RoleName) bool {
+	return roleRanks[actor] > roleRanks[target]
+}
+
+// SuspendUser blocks a user from signing in and ends their sessions
+func SuspendUser(actorID, userID uuid.UUID, reason string) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"suspended_at":     time.Now(),
+			"suspended_reason": reason,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return RevokeSessions(tx, userID, uuid.Nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UnsuspendUser lets a suspended user sign in again
+func UnsuspendUser(actorID, userID uuid.UUID) error {
+	result := config.DB.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+		"suspended_at":     nil,
+		"suspended_reason": "",
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,29 @@
+package services
+
+import (
+	"testing"
+
+	"build-in-public/internal/models"
+)
+
+func TestOutranks(t *testing.T) {
+	tests := []struct {
+		actor, target models.RoleName
+		want          bool
+	}{
+		{models.RoleAdmin, models.RoleModerator, true},
+		{models.RoleAdmin, models.RoleUser, true},
+		{models.RoleModerator, models.RoleUser, true},
+		{models.RoleAdmin, models.RoleAdmin, false},
+		{models.RoleModerator, models.RoleModerator, false},
+		{models.RoleModerator, models.RoleCollegeAdmin, false},
+		{models.RoleModerator, models.RoleAdmin, false},
+		{models.RoleUser, models.RoleUser, false},
+		{"unknown", models.RoleUser, false},
+	}
+	for _, tt := range tests {
+		if got := Outranks(tt.actor, tt.target); got != tt.want {
+			t.Errorf("Outranks(%s, %s) = %v, want %v", tt.actor, tt.target, got, tt.want)
+		}
+	}
+}
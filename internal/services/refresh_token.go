@@ -0,0 +1,162 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshTokenPrefix marks refresh tokens so they are never mistaken for
+// access tokens and can be picked up by secret scanners
+const (
+	RefreshTokenPrefix = "bip_rt_"
+	defaultRefreshTTL  = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused means an already rotated token was presented again
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// RefreshTokenTTL is how long an unused refresh token stays valid. Each
+// rotation starts a new period, so clients in regular use stay signed in.
+var RefreshTokenTTL = defaultRefreshTTL
+
+// InitRefreshTokens reads the refresh token lifetime
+func InitRefreshTokens() {
+	RefreshTokenTTL = durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTTL)
+}
+
+// IssueRefreshToken starts a new token family for the user. The raw token is
+// returned once; only its hash is stored.
+func IssueRefreshToken(c *gin.Context, userID uuid.UUID) (string, *models.RefreshToken, error) {
+	return createRefreshToken(config.DB, c, userID, uuid.New(), time.Now())
+}
+
+// RotateRefreshToken exchanges a refresh token for its successor. Presenting a
+// token that was already rotated revokes its whole family, as either the
+// client or an attacker holds a copy; ErrRefreshTokenReused is returned along
+// with the token so the caller can record whose it was.
+func RotateRefreshToken(c *gin.Context, raw string) (string, *models.RefreshToken, error) {
+	if !strings.HasPrefix(raw, RefreshTokenPrefix) {
+		return "", nil, ErrInvalidRefreshToken
+	}
+
+	now := time.Now()
+	var current models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", HashToken(raw)).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, ErrInvalidRefreshToken
+		}
+		return "", nil, err
+	}
+
+	if current.RevokedAt != nil || !current.ExpiresAt.After(now) {
+		return "", nil, ErrInvalidRefreshToken
+	}
+	if current.UsedAt != nil {
+		revokeReusedFamily(&current, now)
+		return "", &current, ErrRefreshTokenReused
+	}
+
+	var next string
+	var successor *models.RefreshToken
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// The used_at guard makes concurrent refreshes of one token race for a
+		// single winner; the loser is treated as reuse
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		next, successor, err = createRefreshToken(tx, c, current.UserID, current.FamilyID, now)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		revokeReusedFamily(&current, now)
+		return "", &current, err
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return next, successor, nil
+}
+
+// RevokeRefreshToken revokes the family of a raw refresh token, signing that
+// device out. Unknown tokens are ignored.
+func RevokeRefreshToken(raw string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", HashToken(raw)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, revokeRefreshFamily(config.DB, token.FamilyID, time.Now())
+}
+
+// RevokeRefreshTokens revokes every refresh token of the user
+func RevokeRefreshTokens(db *gorm.DB, userID uuid.UUID) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// refreshFamilyActive reports whether the family still has a token that has
+// not been revoked, i.e. the device it was issued to is still signed in
+func refreshFamilyActive(familyID uuid.UUID) (bool, error) {
+	var count int64
+	err := config.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func createRefreshToken(db *gorm.DB, c *gin.Context, userID, familyID uuid.UUID, now time.Time) (string, *models.RefreshToken, error) {
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	raw := RefreshTokenPrefix + secret
+
+	token := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: HashToken(raw),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	}
+	if err := db.Create(token).Error; err != nil {
+		return "", nil, err
+	}
+	return raw, token, nil
+}
+
+func revokeRefreshFamily(db *gorm.DB, familyID uuid.UUID, now time.Time) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", now).Error
+}
+
+func revokeReusedFamily(token *models.RefreshToken, now time.Time) {
+	log.Printf("⚠️ refresh token reuse detected for user %s, revoking family %s", token.UserID, token.FamilyID)
+	if err := revokeRefreshFamily(config.DB, token.FamilyID, now); err != nil {
+		log.Printf("failed to revoke refresh token family: %v", err)
+	}
+}
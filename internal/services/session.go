@@ -0,0 +1,224 @@
+package services
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	SessionCookieName = "session_id"
+
+	defaultIdleTimeout     = 7 * 24 * time.Hour
+	defaultAbsoluteTimeout = 30 * 24 * time.Hour
+
+	// lastSeenInterval throttles how often a session's activity is written back.
+	lastSeenInterval = time.Minute
+)
+
+// SessionPolicyConfig controls how long sessions stay valid
+type SessionPolicyConfig struct {
+	// IdleTimeout is how long a session survives without any authenticated request
+	IdleTimeout time.Duration
+	// AbsoluteTimeout caps the lifetime of a session regardless of activity
+	AbsoluteTimeout time.Duration
+}
+
+var SessionPolicy = SessionPolicyConfig{
+	IdleTimeout:     defaultIdleTimeout,
+	AbsoluteTimeout: defaultAbsoluteTimeout,
+}
+
+func InitSessionPolicy() {
+	SessionPolicy = SessionPolicyConfig{
+		IdleTimeout:     durationFromEnv("SESSION_IDLE_TIMEOUT", defaultIdleTimeout),
+		AbsoluteTimeout: durationFromEnv("SESSION_ABSOLUTE_TIMEOUT", defaultAbsoluteTimeout),
+	}
+	if SessionPolicy.IdleTimeout > SessionPolicy.AbsoluteTimeout {
+		SessionPolicy.IdleTimeout = SessionPolicy.AbsoluteTimeout
+	}
+}
+
+// expiryFor returns when a session active at now should expire under the current policy
+func (p SessionPolicyConfig) expiryFor(session *models.Session, now time.Time) time.Time {
+	expiresAt := now.Add(p.IdleTimeout)
+	if absolute := session.AbsoluteExpiresAt; !absolute.IsZero() && absolute.Before(expiresAt) {
+		expiresAt = absolute
+	}
+	return expiresAt
+}
+
+// IssueSession starts a fresh session for the user and sets its cookie.
+// Any session the request already carries is invalidated first, so a planted
+// session ID can never be carried across a login (session fixation).
+func IssueSession(c *gin.Context, userID uuid.UUID) (*models.Session, error) {
+	now := time.Now()
+	userAgent := c.Request.UserAgent()
+
+	session := models.Session{
+		UserID:            userID,
+		IPAddress:         c.ClientIP(),
+		UserAgent:         userAgent,
+		DeviceLabel:       DeviceLabel(userAgent),
+		LastSeenAt:        now,
+		AbsoluteExpiresAt: now.Add(SessionPolicy.AbsoluteTimeout),
+	}
+	session.ExpiresAt = SessionPolicy.expiryFor(&session, now)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if previous, err := c.Cookie(SessionCookieName); err == nil {
+			if id, err := uuid.Parse(previous); err == nil {
+				if err := tx.Delete(&models.Session{}, "id = ?", id).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Create(&session).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	SetSessionCookie(c, &session)
+	return &session, nil
+}
+
+// RevokeSessions deletes every session of the user except keep; pass uuid.Nil to revoke all of them.
+// The user's refresh tokens are revoked too, signing out native clients.
+func RevokeSessions(db *gorm.DB, userID uuid.UUID, keep uuid.UUID) error {
+	query := db.Where("user_id = ?", userID)
+	if keep != uuid.Nil {
+		query = query.Where("id <> ?", keep)
+	}
+	if err := query.Delete(&models.Session{}).Error; err != nil {
+		return err
+	}
+	return RevokeRefreshTokens(db, userID)
+}
+
+// SessionExpired reports whether the session has passed its idle or absolute deadline
+func SessionExpired(session *models.Session, now time.Time) bool {
+	if session.ExpiresAt.Before(now) {
+		return true
+	}
+	return !session.AbsoluteExpiresAt.IsZero() && session.AbsoluteExpiresAt.Before(now)
+}
+
+// TouchSession records the latest client activity on the session and slides its idle expiry
+func TouchSession(c *gin.Context, session *models.Session) error {
+	now := time.Now()
+	ip := c.ClientIP()
+	userAgent := c.Request.UserAgent()
+
+	if now.Sub(session.LastSeenAt) < lastSeenInterval &&
+		session.IPAddress == ip && session.UserAgent == userAgent {
+		return nil
+	}
+
+	session.LastSeenAt = now
+	session.IPAddress = ip
+	session.UserAgent = userAgent
+	session.DeviceLabel = DeviceLabel(userAgent)
+	session.ExpiresAt = SessionPolicy.expiryFor(session, now)
+
+	if err := config.DB.Model(session).Updates(map[string]any{
+		"last_seen_at": session.LastSeenAt,
+		"ip_address":   session.IPAddress,
+		"user_agent":   session.UserAgent,
+		"device_label": session.DeviceLabel,
+		"expires_at":   session.ExpiresAt,
+	}).Error; err != nil {
+		return err
+	}
+
+	SetSessionCookie(c, session)
+	return nil
+}
+
+// SetSessionCookie writes the session cookie for the given session
+func SetSessionCookie(c *gin.Context, session *models.Session) {
+	maxAge := int(time.Until(session.ExpiresAt).Seconds())
+
+	// SameSite=Lax for localhost compatibility
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(
+		SessionCookieName,
+		session.ID.String(),
+		maxAge,
+		"/",
+		"",
+		false, // true in production (HTTPS)
+		true,  // HttpOnly
+	)
+}
+
+// ClearSessionCookie removes the session cookie from the client
+func ClearSessionCookie(c *gin.Context) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(SessionCookieName, "", -1, "/", "", false, true)
+}
+
+// DeviceLabel builds a human readable label such as "Chrome on Windows" from a user agent
+func DeviceLabel(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	ua := strings.ToLower(userAgent)
+
+	browser := "Unknown browser"
+	switch {
+	case strings.Contains(ua, "edg/"):
+		browser = "Edge"
+	case strings.Contains(ua, "opr/") || strings.Contains(ua, "opera"):
+		browser = "Opera"
+	case strings.Contains(ua, "firefox/"):
+		browser = "Firefox"
+	case strings.Contains(ua, "chrome/") || strings.Contains(ua, "crios/"):
+		browser = "Chrome"
+	case strings.Contains(ua, "safari/"):
+		browser = "Safari"
+	case strings.Contains(ua, "curl/"):
+		browser = "curl"
+	}
+
+	platform := "Unknown OS"
+	switch {
+	case strings.Contains(ua, "android"):
+		platform = "Android"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		platform = "iOS"
+	case strings.Contains(ua, "windows"):
+		platform = "Windows"
+	case strings.Contains(ua, "mac os x") || strings.Contains(ua, "macintosh"):
+		platform = "macOS"
+	case strings.Contains(ua, "cros"):
+		platform = "ChromeOS"
+	case strings.Contains(ua, "linux"):
+		platform = "Linux"
+	}
+
+	return browser + " on " + platform
+}
+
+// durationFromEnv parses a Go duration (e.g. "12h") from the environment, falling back on error
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return fallback
+	}
+	return d
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"build-in-public/internal/config"
+)
+
+// SMSMessage is a plain text SMS to an E.164 number
+type SMSMessage struct {
+	To   string
+	Body string
+}
+
+// SMSSender delivers text messages
+type SMSSender interface {
+	Send(ctx context.Context, msg SMSMessage) error
+}
+
+var SMS SMSSender
+
+// InitSMS selects the sender from SMS_DRIVER: "twilio", "memory" or "console".
+// The driver must be set, and the drivers that never deliver a text are only
+// allowed when APP_ENV=development.
+func InitSMS() {
+	driver := os.Getenv("SMS_DRIVER")
+	switch driver {
+	case "twilio":
+		SMS = &TwilioSender{
+			AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM_NUMBER"),
+			HTTPClient: &http.Client{Timeout: 10 * time.Second},
+		}
+		return
+	case "memory", "console":
+	case "":
+		log.Fatal("❌ SMS_DRIVER is not set (use twilio, or console in development)")
+	default:
+		log.Fatalf("❌ Unknown SMS_DRIVER %q", driver)
+	}
+
+	if !config.IsDevelopment() {
+		log.Fatalf("❌ SMS_DRIVER=%s only prints codes and is not allowed outside development (set APP_ENV=development or SMS_DRIVER=twilio)", driver)
+	}
+	if driver == "memory" {
+		SMS = &MemorySMSSender{}
+		return
+	}
+	SMS = &ConsoleSMSSender{}
+	log.Println("📱 SMS is printed to the log (set SMS_DRIVER=twilio to send for real)")
+}
+
+// TwilioSender sends SMS through the Twilio Messages API
+type TwilioSender struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	HTTPClient *http.Client
+}
+
+func (s *TwilioSender) Send(ctx context.Context, msg SMSMessage) error {
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + url.PathEscape(s.AccountSID) + "/Messages.json"
+	form := url.Values{
+		"To":   {msg.To},
+		"From": {s.From},
+		"Body": {msg.Body},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.AccountSID, s.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send sms: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to send sms: twilio returned %d: %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+// ConsoleSMSSender prints messages to the log, for local development
+type ConsoleSMSSender struct{}
+
+func (s *ConsoleSMSSender) Send(ctx context.Context, msg SMSMessage) error {
+	log.Printf("📱 SMS to %s: %s", msg.To, msg.Body)
+	return nil
+}
+
+// MemorySMSSender keeps sent messages in memory, for tests
+type MemorySMSSender struct {
+	mu       sync.Mutex
+	messages []SMSMessage
+}
+
+func (s *MemorySMSSender) Send(ctx context.Context, msg SMSMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of every message sent so far
+func (s *MemorySMSSender) Messages() []SMSMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]SMSMessage(nil), s.messages...)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidToken is returned when a token is unknown, expired or already used
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// IssueUserToken creates a single-use token for the user, replacing any
+// unused token previously issued for the same purpose. The raw token is
+// returned to be sent to the user; only its hash is persisted.
+func IssueUserToken(userID uuid.UUID, purpose models.TokenPurpose, ttl time.Duration) (string, error) {
+	var raw string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		raw, err = IssueUserTokenTx(tx, userID, purpose, ttl)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// IssueUserTokenTx is IssueUserToken inside the caller's transaction, so the
+// token only exists if the change it belongs to commits with it
+func IssueUserTokenTx(tx *gorm.DB, userID uuid.UUID, purpose models.TokenPurpose, ttl time.Duration) (string, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Delete(&models.UserToken{}).Error; err != nil {
+		return "", err
+	}
+	if err := tx.Create(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: HashToken(raw),
+		ExpiresAt: time.Now().Add(ttl),
+	}).Error; err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// PeekUserToken looks up a valid, unused token without consuming it, so a
+// request can be checked before the token is spent
+func PeekUserToken(raw string, purpose models.TokenPurpose) (*models.UserToken, error) {
+	if raw == "" {
+		return nil, ErrInvalidToken
+	}
+
+	var token models.UserToken
+	if err := config.DB.Where(
+		"token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+		HashToken(raw), purpose, time.Now(),
+	).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// ConsumeUserToken validates a raw token for the given purpose and marks it used.
+// A token can be consumed at most once, even under concurrent requests.
+func ConsumeUserToken(raw string, purpose models.TokenPurpose) (*models.UserToken, error) {
+	var token *models.UserToken
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		token, err = ConsumeUserTokenTx(tx, raw, purpose)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ConsumeUserTokenTx is ConsumeUserToken inside the caller's transaction, so
+// the token is only spent if the change it authorises commits with it
+func ConsumeUserTokenTx(tx *gorm.DB, raw string, purpose models.TokenPurpose) (*models.UserToken, error) {
+	if raw == "" {
+		return nil, ErrInvalidToken
+	}
+
+	now := time.Now()
+	var token models.UserToken
+	if err := tx.Where(
+		"token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?",
+		HashToken(raw), purpose, now,
+	).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	result := tx.Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// HashToken returns the hex encoded SHA-256 digest used to store secrets at rest
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// randomToken returns n random bytes encoded as URL-safe base64 without padding
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// constantTimeEqual compares two secrets without leaking where they differ
+func constantTimeEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
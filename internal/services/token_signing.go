@@ -0,0 +1,244 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"build-in-public/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	accessTokenType     = "at+jwt"
+	defaultAccessTTL    = 15 * time.Minute
+	accessTokenLeeway   = 30 * time.Second
+	accessTokenAudience = "build-in-public-api"
+)
+
+var ErrInvalidAccessJWT = errors.New("invalid or expired access token")
+
+// AccessTokenClaims are the claims of the short-lived JWTs handed to native
+// clients. SessionID names the refresh token family the token was issued from.
+type AccessTokenClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+}
+
+// UserID parses the subject claim
+func (c *AccessTokenClaims) UserID() (uuid.UUID, error) {
+	return uuid.Parse(c.Subject)
+}
+
+type signingKeyring struct {
+	activeID string
+	keys     map[string]ed25519.PrivateKey
+}
+
+var (
+	signingMu      sync.RWMutex
+	signingKeys    *signingKeyring
+	AccessTokenTTL = defaultAccessTTL
+)
+
+// InitTokenSigning loads the Ed25519 keys access tokens are signed with.
+// Every listed key is published in the JWKS, so a new key can be added and
+// rolled out before it becomes active, and an old one kept until the tokens it
+// signed have expired. Without keys a temporary one is generated, which is
+// only allowed when APP_ENV=development.
+func InitTokenSigning() {
+	AccessTokenTTL = durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTTL)
+
+	raw := os.Getenv("JWT_SIGNING_KEYS")
+	if raw == "" {
+		if !config.IsDevelopment() {
+			log.Fatal("❌ JWT_SIGNING_KEYS is not set; it is required outside development")
+		}
+		log.Println("⚠️ JWT_SIGNING_KEYS not set, using a temporary key; access tokens will not survive a restart")
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal("❌ Failed to generate signing key:", err)
+		}
+		setSigningKeys(&signingKeyring{activeID: "dev", keys: map[string]ed25519.PrivateKey{"dev": key}})
+		return
+	}
+
+	ring, err := parseSigningKeys(raw, os.Getenv("JWT_ACTIVE_SIGNING_KEY"))
+	if err != nil {
+		log.Fatal("❌ Invalid JWT signing keys:", err)
+	}
+	setSigningKeys(ring)
+}
+
+func setSigningKeys(ring *signingKeyring) {
+	signingMu.Lock()
+	signingKeys = ring
+	signingMu.Unlock()
+}
+
+func parseSigningKeys(raw, activeID string) (*signingKeyring, error) {
+	ring := &signingKeyring{keys: map[string]ed25519.PrivateKey{}}
+
+	for _, entry := range strings.Split(raw, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("malformed key entry %q", entry)
+		}
+
+		seed, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("key %q must be a 32 byte base64 seed", id)
+		}
+		ring.keys[id] = ed25519.NewKeyFromSeed(seed)
+
+		if activeID == "" {
+			activeID = id
+		}
+	}
+
+	if _, ok := ring.keys[activeID]; !ok {
+		return nil, fmt.Errorf("active key %q is not in the key list", activeID)
+	}
+	ring.activeID = activeID
+	return ring, nil
+}
+
+// SignAccessToken issues an access token for the user, valid for AccessTokenTTL
+func SignAccessToken(userID, familyID uuid.UUID, now time.Time) (string, time.Time, error) {
+	signingMu.RLock()
+	ring := signingKeys
+	signingMu.RUnlock()
+	if ring == nil {
+		return "", time.Time{}, errors.New("token signing is not initialised")
+	}
+
+	expiresAt := now.Add(AccessTokenTTL)
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, AccessTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    BaseURL(),
+			Subject:   userID.String(),
+			Audience:  jwt.ClaimStrings{accessTokenAudience},
+			ID:        uuid.NewString(),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		SessionID: familyID.String(),
+	})
+	token.Header["kid"] = ring.activeID
+	token.Header["typ"] = accessTokenType
+
+	signed, err := token.SignedString(ring.keys[ring.activeID])
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// VerifyAccessToken checks the signature and claims of an access token
+func VerifyAccessToken(raw string, now time.Time) (*AccessTokenClaims, error) {
+	signingMu.RLock()
+	ring := signingKeys
+	signingMu.RUnlock()
+	if ring == nil {
+		return nil, ErrInvalidAccessJWT
+	}
+
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(BaseURL()),
+		jwt.WithAudience(accessTokenAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(accessTokenLeeway),
+		jwt.WithTimeFunc(func() time.Time { return now }),
+	)
+
+	var claims AccessTokenClaims
+	_, err := parser.ParseWithClaims(raw, &claims, func(token *jwt.Token) (any, error) {
+		// Only our access tokens, never an ID token or other JWT signed with the same key
+		if typ, _ := token.Header["typ"].(string); typ != accessTokenType {
+			return nil, ErrInvalidAccessJWT
+		}
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ring.keys[kid]
+		if !ok {
+			return nil, ErrKeyNotFound
+		}
+		return key.Public(), nil
+	})
+	if err != nil {
+		return nil, ErrInvalidAccessJWT
+	}
+	return &claims, nil
+}
+
+// AuthenticateAccessToken verifies an access token and checks that the refresh
+// token family it was issued from has not been revoked, so signing a device
+// out or resetting the password cuts off its access tokens at once. Services
+// that verify tokens offline against the JWKS cannot see revocations and
+// accept a token until it expires, at most AccessTokenTTL later.
+func AuthenticateAccessToken(raw string, now time.Time) (*AccessTokenClaims, error) {
+	claims, err := VerifyAccessToken(raw, now)
+	if err != nil {
+		return nil, err
+	}
+
+	familyID, err := uuid.Parse(claims.SessionID)
+	if err != nil {
+		return nil, ErrInvalidAccessJWT
+	}
+	active, err := refreshFamilyActive(familyID)
+	if err != nil {
+		return nil, err
+	}
+	if !active {
+		return nil, ErrInvalidAccessJWT
+	}
+	return claims, nil
+}
+
+// PublicSigningKeys returns the JWKS other services use to verify access tokens
+func PublicSigningKeys() JSONWebKeySet {
+	signingMu.RLock()
+	ring := signingKeys
+	signingMu.RUnlock()
+
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	if ring == nil {
+		return set
+	}
+
+	ids := make([]string, 0, len(ring.keys))
+	for id := range ring.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	// The active key first, as some clients only look at the first entry
+	if i := slices.Index(ids, ring.activeID); i > 0 {
+		ids[0], ids[i] = ids[i], ids[0]
+	}
+
+	for _, id := range ids {
+		pub := ring.keys[id].Public().(ed25519.PublicKey)
+		set.Keys = append(set.Keys, JSONWebKey{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			Kid: id,
+			Use: "sig",
+			Alg: "EdDSA",
+			X:   base64.RawURLEncoding.EncodeToString(pub),
+		})
+	}
+	return set
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func useTestSigningKey(t *testing.T) ed25519.PrivateKey {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setSigningKeys(&signingKeyring{activeID: "test", keys: map[string]ed25519.PrivateKey{"test": key}})
+	t.Cleanup(func() { setSigningKeys(nil) })
+	return key
+}
+
+func TestAccessTokenRoundTrip(t *testing.T) {
+	useTestSigningKey(t)
+	now := time.Now()
+	userID, familyID := uuid.New(), uuid.New()
+
+	raw, _, err := SignAccessToken(userID, familyID, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := VerifyAccessToken(raw, now)
+	if err != nil {
+		t.Fatalf("VerifyAccessToken: %v", err)
+	}
+	if got, _ := claims.UserID(); got != userID {
+		t.Fatalf("user = %s, want %s", got, userID)
+	}
+	if claims.SessionID != familyID.String() {
+		t.Fatalf("sid = %s, want %s", claims.SessionID, familyID)
+	}
+
+	if _, err := VerifyAccessToken(raw, now.Add(AccessTokenTTL+time.Minute)); err == nil {
+		t.Fatal("expired token accepted")
+	}
+}
+
+func TestVerifyAccessTokenRejectsOtherJWTs(t *testing.T) {
+	key := useTestSigningKey(t)
+	now := time.Now()
+
+	// Correctly signed, but not typed as an access token
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{
+		Issuer:    BaseURL(),
+		Subject:   uuid.NewString(),
+		Audience:  jwt.ClaimStrings{accessTokenAudience},
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+	})
+	token.Header["kid"] = "test"
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := VerifyAccessToken(raw, now); err == nil {
+		t.Fatal("untyped JWT accepted as an access token")
+	}
+}
@@ -0,0 +1,27 @@
+package services
+
+import "os"
+
+// BaseURL is the public URL of this API
+func BaseURL() string {
+	if baseURL := os.Getenv("BASE_URL"); baseURL != "" {
+		return baseURL
+	}
+	return "http://localhost:8080"
+}
+
+// FrontendURL is the public URL of the SvelteKit frontend
+func FrontendURL() string {
+	if frontendURL := os.Getenv("FRONTEND_URL"); frontendURL != "" {
+		return frontendURL
+	}
+	return "http://localhost:5173"
+}
+
+// AppName is the product name shown in authenticator apps, passkey prompts and SMS
+func AppName() string {
+	if name := os.Getenv("APP_NAME"); name != "" {
+		return name
+	}
+	return "Build in Public"
+}
@@ -0,0 +1,317 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	ceremonyRegistration = "registration"
+	ceremonyLogin        = "login"
+	ceremonyTTL          = 5 * time.Minute
+)
+
+var (
+	ErrCeremonyNotFound = errors.New("passkey ceremony expired or not found")
+	ErrPasskeyCloned    = errors.New("passkey signature counter went backwards")
+)
+
+var WebAuthn *webauthn.WebAuthn
+
+// InitWebAuthn configures the relying party. The frontend origin is the only
+// origin allowed to run passkey ceremonies.
+func InitWebAuthn() {
+	origin := FrontendURL()
+
+	rpID := os.Getenv("WEBAUTHN_RP_ID")
+	if rpID == "" {
+		if u, err := url.Parse(origin); err == nil {
+			rpID = u.Hostname()
+		}
+	}
+
+	displayName := AppName()
+
+	var err error
+	WebAuthn, err = webauthn.New(&webauthn.Config{
+		RPID:          rpID,
+		RPDisplayName: displayName,
+		RPOrigins:     []string{origin},
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			ResidentKey:      protocol.ResidentKeyRequirementRequired,
+			UserVerification: protocol.VerificationRequired,
+		},
+	})
+	if err != nil {
+		log.Fatal("❌ WebAuthn configuration failed:", err)
+	}
+}
+
+// passkeyUser adapts models.User to the webauthn.User interface
+type passkeyUser struct {
+	user        models.User
+	credentials []models.WebAuthnCredential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	id := u.user.ID
+	return id[:]
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	return u.user.Email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	if u.user.LastName != nil && *u.user.LastName != "" {
+		return u.user.FirstName + " " + *u.user.LastName
+	}
+	return u.user.FirstName
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	credentials := make([]webauthn.Credential, 0, len(u.credentials))
+	for _, c := range u.credentials {
+		credentials = append(credentials, toWebAuthnCredential(c))
+	}
+	return credentials
+}
+
+// BeginPasskeyRegistration starts registering a new passkey for the user
+func BeginPasskeyRegistration(user models.User) (*protocol.CredentialCreation, uuid.UUID, error) {
+	pu, err := loadPasskeyUser(config.DB, user)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	creation, data, err := WebAuthn.BeginRegistration(
+		pu,
+		webauthn.WithExclusions(webauthn.Credentials(pu.WebAuthnCredentials()).CredentialDescriptors()),
+	)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	ceremonyID, err := saveCeremony(&user.ID, ceremonyRegistration, data)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return creation, ceremonyID, nil
+}
+
+// FinishPasskeyRegistration verifies the authenticator's attestation response and stores the new passkey
+func FinishPasskeyRegistration(user models.User, ceremonyID uuid.UUID, name string, r *http.Request) (*models.WebAuthnCredential, error) {
+	data, err := takeCeremony(ceremonyID, ceremonyRegistration, &user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	pu, err := loadPasskeyUser(config.DB, user)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := WebAuthn.FinishRegistration(pu, *data, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if name == "" {
+		name = "Passkey"
+	}
+
+	transports := make([]string, 0, len(credential.Transport))
+	for _, t := range credential.Transport {
+		transports = append(transports, string(t))
+	}
+
+	record := models.WebAuthnCredential{
+		UserID:          user.ID,
+		Name:            name,
+		CredentialID:    credential.ID,
+		PublicKey:       credential.PublicKey,
+		AttestationType: credential.AttestationType,
+		Transports:      strings.Join(transports, ","),
+		AAGUID:          credential.Authenticator.AAGUID,
+		SignCount:       credential.Authenticator.SignCount,
+		BackupEligible:  credential.Flags.BackupEligible,
+		BackupState:     credential.Flags.BackupState,
+	}
+	if err := config.DB.Create(&record).Error; err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// BeginPasskeyLogin starts a discoverable credential login, where the
+// authenticator tells us which user it belongs to
+func BeginPasskeyLogin() (*protocol.CredentialAssertion, uuid.UUID, error) {
+	assertion, data, err := WebAuthn.BeginDiscoverableLogin()
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+
+	ceremonyID, err := saveCeremony(nil, ceremonyLogin, data)
+	if err != nil {
+		return nil, uuid.Nil, err
+	}
+	return assertion, ceremonyID, nil
+}
+
+// FinishPasskeyLogin verifies the assertion and returns the user it authenticates.
+// A signature counter that fails to increase marks the passkey as possibly
+// cloned and the login is refused.
+func FinishPasskeyLogin(ceremonyID uuid.UUID, r *http.Request) (*models.User, error) {
+	data, err := takeCeremony(ceremonyID, ceremonyLogin, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var found *passkeyUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userID, err := uuid.FromBytes(userHandle)
+		if err != nil {
+			return nil, err
+		}
+
+		var user models.User
+		if err := config.DB.First(&user, "id = ?", userID).Error; err != nil {
+			return nil, err
+		}
+
+		found, err = loadPasskeyUser(config.DB, user)
+		return found, err
+	}
+
+	credential, err := WebAuthn.FinishDiscoverableLogin(handler, *data, r)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	updates := map[string]any{
+		"last_used_at": now,
+		"backup_state": credential.Flags.BackupState,
+	}
+	if credential.Authenticator.CloneWarning {
+		updates["clone_warning"] = true
+	} else {
+		updates["sign_count"] = credential.Authenticator.SignCount
+	}
+
+	if err := config.DB.Model(&models.WebAuthnCredential{}).
+		Where("credential_id = ?", credential.ID).
+		Updates(updates).Error; err != nil {
+		return nil, err
+	}
+
+	if credential.Authenticator.CloneWarning {
+		log.Printf("⚠️ passkey clone warning for user %s", found.user.ID)
+		return nil, ErrPasskeyCloned
+	}
+
+	return &found.user, nil
+}
+
+func loadPasskeyUser(db *gorm.DB, user models.User) (*passkeyUser, error) {
+	var credentials []models.WebAuthnCredential
+	if err := db.Where("user_id = ?", user.ID).Find(&credentials).Error; err != nil {
+		return nil, err
+	}
+	return &passkeyUser{user: user, credentials: credentials}, nil
+}
+
+func toWebAuthnCredential(c models.WebAuthnCredential) webauthn.Credential {
+	var transports []protocol.AuthenticatorTransport
+	if c.Transports != "" {
+		for _, t := range strings.Split(c.Transports, ",") {
+			transports = append(transports, protocol.AuthenticatorTransport(t))
+		}
+	}
+
+	return webauthn.Credential{
+		ID:              c.CredentialID,
+		PublicKey:       c.PublicKey,
+		AttestationType: c.AttestationType,
+		Transport:       transports,
+		Flags: webauthn.CredentialFlags{
+			UserPresent:    true,
+			UserVerified:   true,
+			BackupEligible: c.BackupEligible,
+			BackupState:    c.BackupState,
+		},
+		Authenticator: webauthn.Authenticator{
+			AAGUID:       c.AAGUID,
+			SignCount:    c.SignCount,
+			CloneWarning: c.CloneWarning,
+		},
+	}
+}
+
+func saveCeremony(userID *uuid.UUID, purpose string, data *webauthn.SessionData) (uuid.UUID, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	ceremony := models.WebAuthnCeremony{
+		UserID:      userID,
+		Purpose:     purpose,
+		SessionData: string(encoded),
+		ExpiresAt:   time.Now().Add(ceremonyTTL),
+	}
+	if err := config.DB.Create(&ceremony).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return ceremony.ID, nil
+}
+
+// takeCeremony loads and deletes a ceremony so its challenge can only be answered once
+func takeCeremony(id uuid.UUID, purpose string, userID *uuid.UUID) (*webauthn.SessionData, error) {
+	var ceremony models.WebAuthnCeremony
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&ceremony, "id = ? AND purpose = ?", id, purpose).Error; err != nil {
+			return ErrCeremonyNotFound
+		}
+		result := tx.Delete(&models.WebAuthnCeremony{}, "id = ?", ceremony.ID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != 1 {
+			// Another request took the same ceremony first
+			return ErrCeremonyNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if ceremony.ExpiresAt.Before(time.Now()) {
+		return nil, ErrCeremonyNotFound
+	}
+	if userID != nil && (ceremony.UserID == nil || *ceremony.UserID != *userID) {
+		return nil, ErrCeremonyNotFound
+	}
+
+	var data webauthn.SessionData
+	if err := json.Unmarshal([]byte(ceremony.SessionData), &data); err != nil {
+		return nil, fmt.Errorf("failed to decode ceremony: %w", err)
+	}
+	return &data, nil
+}
@@ -0,0 +1,108 @@
+// Package testutil holds helpers shared by package tests that need Postgres.
+package testutil
+
+import (
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"build-in-public/internal/config"
+	"build-in-public/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// DB points config.DB at a fresh, migrated schema in the database named by
+// TEST_DATABASE_URL (a postgres:// URL) and drops the schema when the test
+// ends. Tests calling it are skipped when the variable is unset.
+func DB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	admin, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+
+	schema := "test_" + strings.ReplaceAll(uuid.NewString(), "-", "")[:16]
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatalf("TEST_DATABASE_URL: %v", err)
+	}
+	query := u.Query()
+	query.Set("search_path", schema)
+	u.RawQuery = query.Encode()
+
+	db, err := gorm.Open(postgres.Open(u.String()), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("connect test schema: %v", err)
+	}
+	if err := config.Migrate(db); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	previous := config.DB
+	config.DB = db
+	t.Cleanup(func() {
+		config.DB = previous
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		if sqlDB, err := admin.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// CreateUser inserts a verified user with the default role; edit adjusts the
+// record before it is saved.
+func CreateUser(t *testing.T, edit func(*models.User)) models.User {
+	t.Helper()
+
+	user := models.User{
+		FirstName:     "Test",
+		Email:         uuid.NewString() + "@example.com",
+		EmailVerified: true,
+		Gender:        models.GenderOther,
+		Role:          models.RoleUser,
+	}
+	if edit != nil {
+		edit(&user)
+	}
+	if err := config.DB.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// CreateSession inserts a live browser session for the user
+func CreateSession(t *testing.T, userID uuid.UUID) models.Session {
+	t.Helper()
+
+	now := time.Now()
+	session := models.Session{
+		UserID:            userID,
+		LastSeenAt:        now,
+		ExpiresAt:         now.Add(time.Hour),
+		AbsoluteExpiresAt: now.Add(24 * time.Hour),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	return session
+}